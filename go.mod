@@ -5,16 +5,15 @@ go 1.20
 require (
 	github.com/fatih/color v1.18.0
 	github.com/mattn/go-runewidth v0.0.16
-	github.com/mitchellh/go-wordwrap v1.0.1
 	github.com/pborman/getopt/v2 v2.1.0
+	github.com/rivo/uniseg v0.4.7
 	github.com/schollz/progressbar/v3 v3.13.1
+	golang.org/x/term v0.6.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
-	github.com/rivo/uniseg v0.2.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
-	golang.org/x/term v0.6.0 // indirect
 )
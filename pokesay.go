@@ -1,8 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/pborman/getopt/v2"
@@ -14,9 +20,14 @@ import (
 var (
 	//go:embed build/assets/category_keys.txt
 	GOBCategoryKeys []byte
+	//go:embed build/assets/category_dimensions.txt
+	GOBCategoryDimensions []byte
 	//go:embed build/assets/names.txt
 	GOBAllNames []byte
 
+	//go:embed build/assets/fortunes.txt
+	GOBFortunes []byte
+
 	//go:embed build/assets/total.txt
 	GOBTotal []byte
 	//go:embed build/assets/cows/*cow
@@ -31,126 +42,509 @@ var (
 	CowDataRoot  string = "build/assets/cows"       // the root directory of the pokemon cow data
 )
 
-// parseFlags parses the command line flags and returns a pokesay.Args struct
-func parseFlags() pokesay.Args {
-	help := getopt.BoolLong("help", 'h', "display this help message")
+// command is a pokesay subcommand, e.g. `pokesay list names`
+type command struct {
+	Name        string
+	Parameters  string // the positional parameters, shown in the usage line
+	Description string
+	Run         func(set *getopt.Set, argv []string)
+}
+
+// commands returns all of the available subcommands. If no command is given, then "say" is run,
+// so that `pokesay [flags]` keeps working
+func commands() []command {
+	return []command{
+		{"say", "[message...]", "print a pokemon, with a message (or the text from STDIN) in a speech bubble (the default)", runSay},
+		{"show", "<name>", "print a pokemon without a speech bubble", runShow},
+		{"info", "<name>", "print a pokedex card with every form & category of a pokemon", runInfo},
+		{"list", "names|categories", "list all available names, or categories", runList},
+		{"browse", "", "browse all of the pokemon interactively", runBrowse},
+		{"serve", "", "serve pokemon over HTTP", runServe},
+		{"completion", "bash|zsh|fish", "print a shell completion script", runCompletion},
+	}
+}
+
+// commandNames returns the names of all of the subcommands
+func commandNames() []string {
+	names := make([]string, 0)
+	for _, c := range commands() {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// flags holds the values of the command line flags that control how pokemon are chosen & printed.
+// Flags that aren't defined for a command keep their zero value
+type flags struct {
+	help, verbose                                *bool
+	names, prefer, infoDimensions, languages     *[]string
+	colourFilters                                *[]string
+	background                                   *string
+	category                                     *string
+	maxWidth, maxHeight, count, maxLines         *int
+	split, width, animate, listFormat            *string
+	wrap, align, fortuneFile, file               *string
+	title, footer, spriteAlign, spriteAlignTo    *string
+	infoFormat, infoPosition                     *string
+	fortune                                      *bool
+	listNames, listCategories                    *bool
+	tabWidth, fps, indent                        *int
+	noWrap, noTabSpaces, fastest, noBubble       *bool
+	stripInputANSI, markup, head, tail, pager    *bool
+	japaneseName, noCategoryInfo, drawInfoBorder *bool
+	unicodeBorders                               *bool
+}
+
+// defineFlags defines the flags for choosing & printing pokemon on a flag set
+// - If speech is true, the flags for the speech bubble, scenes, animations & the old list
+// operations are also defined (i.e. for the say command)
+func defineFlags(set *getopt.Set, speech bool) *flags {
+	f := &flags{
+		names: new([]string), split: new(string), animate: new(string), count: new(int),
+		listNames: new(bool), listCategories: new(bool), listFormat: new(string), wrap: new(string), align: new(string), fortuneFile: new(string), file: new(string), title: new(string), footer: new(string), fortune: new(bool), tabWidth: new(int), fps: new(int),
+		noWrap: new(bool), noTabSpaces: new(bool), fastest: new(bool), noBubble: new(bool),
+		stripInputANSI: new(bool), markup: new(bool), maxLines: new(int), head: new(bool), tail: new(bool), pager: new(bool),
+	}
+	f.help = set.BoolLong("help", 'h', "display this help message")
 	// print verbose output (currently timer output)
-	verbose := getopt.BoolLong("verbose", 'v', "print verbose output", "verbose")
+	f.verbose = set.BoolLong("verbose", 'v', "print verbose output", "verbose")
 
 	// selection/filtering
-	name := getopt.StringLong("name", 'n', "", "choose a pokemon from a specific name")
-	category := getopt.StringLong("category", 'c', "", "choose a pokemon from a specific category")
-
-	// list operations
-	listNames := getopt.BoolLong("list-names", 'l', "list all available names")
-	listCategories := getopt.BoolLong("list-categories", 'L', "list all available categories")
+	if speech {
+		f.names = set.ListLong("name", 'n', "choose a pokemon from a specific name (can be given multiple times, or comma-separated, to choose multiple pokemon)")
+	}
+	f.category = set.StringLong("category", 'c', "", "choose a pokemon from a specific category, optionally with its dimension (e.g. shiny or variant=shiny)")
+	f.prefer = set.ListLong("prefer", 0, "prefer categories in a dimension, in order, falling back to any category (e.g. gen=gen8,gen7x)")
+	f.maxWidth = set.IntLong("max-width", 0, 0, "only choose pokemon that are at most N columns wide")
+	f.maxHeight = set.IntLong("max-height", 0, 0, "only choose pokemon that are at most N lines tall")
+
+	if speech {
+		// multiple pokemon
+		f.count = set.IntLong("count", 0, 1, "the number of pokemon to print side by side")
+		f.file = set.StringLong("file", 0, "", "read the message from a file, or '-' for STDIN")
+		f.fortune = set.BoolLong("fortune", 0, "print a random fortune instead of reading STDIN (also used when STDIN is a terminal or empty)")
+		f.fortuneFile = set.StringLong("fortune-file", 0, "", "read fortunes from a fortune file, or a directory of them (with fortunes separated by '%' lines), instead of the built-in fortunes")
+		f.split = set.StringLong("split", 0, "", "split STDIN into one message per pokemon (or per speech bubble, with a single pokemon), using lines that match a delimiter (e.g. ---), or 'lines' for one message per line")
+
+		// list operations
+		f.listNames = set.BoolLong("list-names", 'l', "list all available names")
+		f.listCategories = set.BoolLong("list-categories", 'L', "list all available categories")
+		f.listFormat = set.StringLong("format", 'F', "columns", "the output format of the lists, one of: "+strings.Join(pokesay.ListFormats, ", "))
+	}
 
-	width := getopt.IntLong("width", 'w', 80, "the max speech bubble width")
+	f.width = set.StringLong("width", 'w', "80", "the max speech bubble width, or 'auto' to fit the bubble and pokemon to the terminal width")
+
+	if speech {
+		// speech bubble options
+		f.tabWidth = set.IntLong("tab-width", 't', 4, "replace any tab characters with N spaces")
+		f.wrap = set.StringLong("wrap", 0, "word", "how to wrap text, one of: "+strings.Join(pokesay.WrapModes, ", "))
+		f.align = set.StringLong("align", 0, "left", "how to align text in the speech bubble, one of: "+strings.Join(pokesay.Alignments, ", "))
+		f.noWrap = set.BoolLong("no-wrap", 'W', "disable text wrapping, the same as --wrap none (fastest)")
+		f.noTabSpaces = set.BoolLong("no-tab-spaces", 's', "do not replace tab characters (fastest)")
+		f.fastest = set.BoolLong("fastest", 'f', "run with the fastest possible configuration (--nowrap & --notabspaces)")
+		f.noBubble = set.BoolLong("no-bubble", 'B', "do not draw the speech bubble")
+		f.title = set.StringLong("title", 0, "", "print a title in the top border of the speech bubble")
+		f.footer = set.StringLong("footer", 0, "", "print a footer in the bottom border of the speech bubble, or 'auto' to use a fortune-style '-- Author' line at the end of the text")
+		f.maxLines = set.IntLong("max-lines", 0, 0, "print at most N lines of text in the speech bubble, followed by the number of lines that were cut off")
+		f.head = set.BoolLong("head", 0, "keep the first lines of text with --max-lines (the default)")
+		f.tail = set.BoolLong("tail", 0, "keep the last lines of text with --max-lines, or the first & last lines if --head is also given")
+		f.pager = set.BoolLong("pager", 0, "print the output through $PAGER (or less) if it's taller than the terminal")
+		f.markup = set.BoolLong("markup", 0, "render **bold**, *italic*, `code` and [red]colour[/] markup in the text")
+		f.stripInputANSI = set.BoolLong("strip-input-ansi", 0, "remove all escape sequences (e.g. colours) from the text")
+	}
 
-	// speech bubble options
-	tabWidth := getopt.IntLong("tab-width", 't', 4, "replace any tab characters with N spaces")
-	noWrap := getopt.BoolLong("no-wrap", 'W', "disable text wrapping (fastest)")
-	noTabSpaces := getopt.BoolLong("no-tab-spaces", 's', "do not replace tab characters (fastest)")
-	fastest := getopt.BoolLong("fastest", 'f', "run with the fastest possible configuration (--nowrap & --notabspaces)")
-	noBubble := getopt.BoolLong("no-bubble", 'B', "do not draw the speech bubble")
+	// sprite options
+	f.spriteAlign = set.StringLong("sprite-align", 0, "left", "how to align the pokemon, one of: "+strings.Join(pokesay.SpriteAlignments, ", "))
+	f.spriteAlignTo = set.StringLong("sprite-align-to", 0, "bubble", "align the pokemon within the width of the speech bubble, or the terminal, one of: "+strings.Join(pokesay.SpriteAlignTargets, ", "))
+	f.indent = set.IntLong("indent", 0, pokesay.DefaultIndent, "print N spaces before the pokemon")
+	f.colourFilters = set.ListLong("filter", 0, "apply colour filters to the pokemon, in order (e.g. grayscale,hue:+120), from: "+strings.Join(pokesay.ColourFilters, ", "))
 
 	// info box options
-	japaneseName := getopt.BoolLong("japanese-name", 'j', "print the japanese name in the info box")
-	noCategoryInfo := getopt.BoolLong("no-category-info", 'C', "do not print pokemon category information in the info box")
-	drawInfoBorder := getopt.BoolLong("info-border", 'b', "draw a border around the info box")
+	f.japaneseName = set.BoolLong("japanese-name", 'j', "print the japanese name in the info box")
+	f.languages = set.ListLong("lang", 0, "print the name in these languages in the info box, in order (e.g. zh,ja,en), from: "+strings.Join(pokedex.Languages, ", "))
+	f.noCategoryInfo = set.BoolLong("no-category-info", 'C', "do not print pokemon category information in the info box")
+	f.drawInfoBorder = set.BoolLong("info-border", 'b', "draw a border around the info box")
+	f.infoDimensions = set.ListLong("info-dimensions", 0, "only print the categories of these dimensions in the info box, in order (e.g. gen,variant)")
+	f.infoFormat = set.StringLong("info-format", 0, "", "print the info box from a template (instead of -j & -C), e.g. '{name} #{dex} | {jp} ({romaji})\\n{categories}', with the placeholders: "+strings.Join(pokesay.InfoPlaceholders, ", ")+", or any dimension (e.g. {gen}). The text can use the same markup as --markup")
+	f.infoPosition = set.StringLong("info-position", 0, "below", "where to print the info box, one of: "+strings.Join(pokesay.InfoPositions, ", ")+" (with above, the speech bubble points at the info box)")
+
+	if speech {
+		// animation options
+		f.animate = set.StringLong("animate", 'a', "", "animate the output of a single pokemon, one of: type, bob, sparkle (bob & sparkle run until Ctrl-C is pressed)")
+		f.fps = set.IntLong("fps", 0, 0, "the number of animation frames per second (defaults to 30 for type, 2 for bob, 4 for sparkle)")
+	}
 
 	// other option
-	unicodeBorders := getopt.BoolLong("unicode-borders", 'u', "use unicode characters to draw the border around the speech box (and info box if --info-border is enabled)")
+	f.unicodeBorders = set.BoolLong("unicode-borders", 'u', "use unicode characters to draw the border around the speech box (and info box if --info-border is enabled)")
+	f.background = set.StringLong("background", 0, pokesay.DefaultBackground, "adjust the colours of the pokemon & info box for the terminal background, one of: "+strings.Join(pokesay.Backgrounds, ", ")+" (auto asks the terminal for its background colour)")
+
+	return f
+}
 
-	getopt.Parse()
+// args converts the parsed flags into a pokesay.Args struct
+func (f *flags) args() pokesay.Args {
 	var args pokesay.Args
 
-	if *fastest {
+	if _, ok := pokesay.Animations[*f.animate]; *f.animate != "" && !ok {
+		log.Fatalf("invalid animation '%s', must be one of: type, bob, sparkle", *f.animate)
+	}
+
+	if *f.wrap != "" && !pokedex.ContainsString(pokesay.WrapModes, *f.wrap) {
+		log.Fatalf("invalid wrap mode '%s', must be one of: %s", *f.wrap, strings.Join(pokesay.WrapModes, ", "))
+	}
+	if *f.align != "" && !pokedex.ContainsString(pokesay.Alignments, *f.align) {
+		log.Fatalf("invalid alignment '%s', must be one of: %s", *f.align, strings.Join(pokesay.Alignments, ", "))
+	}
+
+	if !pokedex.ContainsString(pokesay.SpriteAlignments, *f.spriteAlign) {
+		log.Fatalf("invalid sprite alignment '%s', must be one of: %s", *f.spriteAlign, strings.Join(pokesay.SpriteAlignments, ", "))
+	}
+	if !pokedex.ContainsString(pokesay.SpriteAlignTargets, *f.spriteAlignTo) {
+		log.Fatalf("invalid sprite alignment target '%s', must be one of: %s", *f.spriteAlignTo, strings.Join(pokesay.SpriteAlignTargets, ", "))
+	}
+	for _, language := range *f.languages {
+		if !pokedex.ContainsString(pokedex.Languages, language) {
+			log.Fatalf("invalid language '%s', must be one of: %s", language, strings.Join(pokedex.Languages, ", "))
+		}
+	}
+	// "\n" in the info format starts a new line
+	infoFormat := strings.ReplaceAll(*f.infoFormat, "\\n", "\n")
+	if err := pokesay.ValidateInfoFormat(infoFormat); err != nil {
+		log.Fatal(err)
+	}
+	if !pokedex.ContainsString(pokesay.InfoPositions, *f.infoPosition) {
+		log.Fatalf("invalid info position '%s', must be one of: %s", *f.infoPosition, strings.Join(pokesay.InfoPositions, ", "))
+	}
+	colourFilters := make([]pokesay.ColourFilter, 0, len(*f.colourFilters))
+	for _, name := range *f.colourFilters {
+		filter, err := pokesay.ParseColourFilter(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		colourFilters = append(colourFilters, filter)
+	}
+	if !pokedex.ContainsString(pokesay.Backgrounds, *f.background) {
+		log.Fatalf("invalid background '%s', must be one of: %s", *f.background, strings.Join(pokesay.Backgrounds, ", "))
+	}
+	if *f.indent < 0 {
+		log.Fatalf("invalid indent '%d', must be a positive number", *f.indent)
+	}
+
+	if *f.maxLines < 0 {
+		log.Fatalf("invalid max lines '%d', must be a positive number", *f.maxLines)
+	}
+	if *f.pager && *f.animate != "" {
+		log.Fatal("cannot use --pager with --animate")
+	}
+	if *f.animate != "" && (*f.count > 1 || len(*f.names) > 1 || *f.split != "") {
+		log.Fatal("cannot use --animate with multiple pokemon (--count or more than one --name) or --split")
+	}
+
+	bubbleWidth, filter := parseWidth(*f.width)
+	if filter.MaxWidth > 0 {
+		// the pokemon are printed after the indent, which also has to fit within the terminal
+		filter.MaxWidth -= *f.indent
+	}
+	if *f.maxWidth > 0 && (filter.MaxWidth == 0 || *f.maxWidth < filter.MaxWidth) {
+		filter.MaxWidth = *f.maxWidth
+	}
+	filter.MaxHeight = *f.maxHeight
+
+	index := pokedex.ReadStructFromBytes[[]pokedex.CategoryDimension](GOBCategoryDimensions)
+	category, err := pokesay.ParseCategory(*f.category, index)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filter.Prefer, err = pokesay.ParsePreferences(*f.prefer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, preference := range filter.Prefer {
+		for _, value := range preference.Values {
+			if pokedex.FindDimension(index, value) != preference.Dimension {
+				log.Fatalf("cannot find category '%s' in dimension '%s'", value, preference.Dimension)
+			}
+		}
+	}
+
+	if *f.fastest {
 		args = pokesay.Args{
-			Width:       *width,
-			NoWrap:      true,
-			TabSpaces:   "    ",
-			NoTabSpaces: true,
-			BoxChars:    pokesay.DetermineBoxChars(false),
-			Help:        *help,
-			Verbose:     *verbose,
+			Width:         bubbleWidth,
+			NoWrap:        true,
+			Wrap:          "none",
+			TabSpaces:     "    ",
+			NoTabSpaces:   true,
+			SpriteAlign:   *f.spriteAlign,
+			SpriteAlignTo: *f.spriteAlignTo,
+			Indent:        *f.indent,
+			ColourFilters: colourFilters,
+			Background:    *f.background,
+			Languages:     *f.languages,
+			InfoFormat:    infoFormat,
+			InfoPosition:  *f.infoPosition,
+			BoxChars:      pokesay.DetermineBoxChars(false),
+			Filter:        filter,
+			Help:          *f.help,
+			Verbose:       *f.verbose,
 		}
 	} else {
 		args = pokesay.Args{
-			Width:          *width,
-			NoWrap:         *noWrap,
-			DrawBubble:     !*noBubble,
-			TabSpaces:      strings.Repeat(" ", *tabWidth),
-			NoTabSpaces:    *noTabSpaces,
-			NoCategoryInfo: *noCategoryInfo,
-			ListCategories: *listCategories,
-			ListNames:      *listNames,
-			Category:       *category,
-			NameToken:      *name,
-			JapaneseName:   *japaneseName,
-			BoxChars:       pokesay.DetermineBoxChars(*unicodeBorders),
-			DrawInfoBorder: *drawInfoBorder,
-			Help:           *help,
-			Verbose:        *verbose,
+			Width:          bubbleWidth,
+			NoWrap:         *f.noWrap || *f.wrap == "none",
+			Wrap:           *f.wrap,
+			Align:          *f.align,
+			DrawBubble:     !*f.noBubble,
+			TabSpaces:      strings.Repeat(" ", *f.tabWidth),
+			NoTabSpaces:    *f.noTabSpaces,
+			StripInputANSI: *f.stripInputANSI,
+			Markup:         *f.markup,
+			Title:          *f.title,
+			Footer:         *f.footer,
+			MaxLines:       *f.maxLines,
+			Head:           *f.head,
+			Tail:           *f.tail,
+			SpriteAlign:    *f.spriteAlign,
+			SpriteAlignTo:  *f.spriteAlignTo,
+			Indent:         *f.indent,
+			ColourFilters:  colourFilters,
+			Background:     *f.background,
+			NoCategoryInfo: *f.noCategoryInfo,
+			ListCategories: *f.listCategories,
+			ListNames:      *f.listNames,
+			ListFormat:     *f.listFormat,
+			Category:       category,
+			NameTokens:     *f.names,
+			Count:          *f.count,
+			SplitDelimiter: *f.split,
+			JapaneseName:   *f.japaneseName,
+			Languages:      *f.languages,
+			BoxChars:       pokesay.DetermineBoxChars(*f.unicodeBorders),
+			DrawInfoBorder: *f.drawInfoBorder,
+			InfoDimensions: *f.infoDimensions,
+			InfoFormat:     infoFormat,
+			InfoPosition:   *f.infoPosition,
+			Filter:         filter,
+			Animate:        *f.animate,
+			FPS:            *f.fps,
+			Help:           *f.help,
+			Verbose:        *f.verbose,
 		}
 	}
+	if args.Verbose {
+		fmt.Println("Verbose output enabled")
+		timer.DEBUG = true
+	}
 	return args
 }
 
+// parseArgs parses the flags in argv, and returns the positional parameters (see pokesay.ParseParams)
+// - If the -h/--help flag is given, then the usage of the command is printed, and the program exits
+func parseArgs(set *getopt.Set, argv []string) []string {
+	params, help := pokesay.ParseParams(set, argv)
+	if help {
+		printUsage(set, os.Stdout)
+		os.Exit(0)
+	}
+	return params
+}
+
+// newCommandSet returns a flag set for a subcommand
+func newCommandSet(c command) *getopt.Set {
+	set := getopt.New()
+	set.SetProgram("pokesay " + c.Name)
+	set.SetParameters(c.Parameters)
+	return set
+}
+
+// resolveBackground asks the terminal for its background, if the background is "auto"
+// - This is only done right before printing a pokemon, so that other commands never query the terminal
+func resolveBackground(background string) string {
+	if background == "auto" {
+		return pokesay.DetectBackground()
+	}
+	return background
+}
+
+// parseWidth parses the --width flag value, and returns the speech bubble width and a pokemon filter
+// - If the width is "auto", then the bubble is sized to fit the terminal, and only pokemon that fit
+// within the terminal width can be chosen
+// - Otherwise, the width is used as-is, and any pokemon can be chosen
+func parseWidth(width string) (int, pokesay.EntryFilter) {
+	if width == "auto" {
+		terminalWidth := pokesay.TerminalWidth()
+		// leave room for the bubble edges & padding on either side of the text, e.g. "| " & " |"
+		bubbleWidth := terminalWidth - 4
+		if bubbleWidth < pokesay.MinBubbleWidth {
+			bubbleWidth = pokesay.MinBubbleWidth
+		}
+		return bubbleWidth, pokesay.EntryFilter{MaxWidth: terminalWidth}
+	}
+	bubbleWidth, err := strconv.Atoi(width)
+	if err != nil {
+		log.Fatalf("invalid width '%s', must be a number or 'auto'", width)
+	}
+	return bubbleWidth, pokesay.EntryFilter{}
+}
+
+// readFortunes reads the fortunes from a fortune file or directory, or the built-in fortunes if
+// no file is given
+func readFortunes(fpath string) []string {
+	if fpath == "" {
+		return pokedex.ParseFortunes(string(pokedex.Decompress(GOBFortunes)))
+	}
+	fortunes, err := pokedex.ReadFortuneFiles(fpath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return fortunes
+}
+
+// readAllMetadata reads the metadata of every pokemon from the embedded filesystem
+func readAllMetadata() []pokedex.PokemonMetadata {
+	total := pokedex.ReadIntFromBytes(GOBTotal)
+	metadata := make([]pokedex.PokemonMetadata, 0, total)
+	for i := 0; i < total; i++ {
+		metadata = append(metadata, pokedex.ReadMetadataFromEmbedded(GOBCowNames, pokedex.MetadataFpath(MetadataRoot, i)))
+	}
+	return metadata
+}
+
 // runListCategories prints all available categories
-// - This reads a list of categories from the embedded filesystem
-// - prints the list of categories, and the total number of categories
-func runListCategories() {
-	categories := pokedex.ReadStructFromBytes[[]string](GOBCategoryKeys)
-	fmt.Printf("%s\n%d %s\n", strings.Join(categories, " "), len(categories), "total categories")
+// - This reads a list of categories, and the metadata of every pokemon, from the embedded filesystem
+// - prints the categories grouped by dimension, with the number of sprites & species in each category
+func runListCategories(format string) {
+	categories := pokesay.CountCategories(
+		pokedex.ReadStructFromBytes[[]string](GOBCategoryKeys),
+		pokedex.ReadStructFromBytes[[]pokedex.CategoryDimension](GOBCategoryDimensions),
+		readAllMetadata(),
+	)
+	if err := pokesay.WriteCategories(os.Stdout, categories, format); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // runListNames prints all available pokemon names
 // - This reads a struct of {name -> metadata indexes} from the embedded filesystem
-// - prints all the keys of the struct, and the total number of names
-func runListNames() {
-	names := pokesay.ListNames(
+// - If a category is given (optionally with its dimension, e.g. variant=shiny), then only the
+// names with a sprite in that category are printed
+func runListNames(category string, format string) {
+	names := pokedex.ReadStructFromBytes[map[string][]int](GOBAllNames)
+	list := pokesay.ListNames(names)
+	if category != "" {
+		var err error
+		list, err = pokesay.NamesInCategory(
+			names, category,
+			pokedex.ReadStructFromBytes[[]string](GOBCategoryKeys),
+			pokedex.ReadStructFromBytes[[]pokedex.CategoryDimension](GOBCategoryDimensions),
+			func(idx int) pokedex.PokemonMetadata {
+				return pokedex.ReadMetadataFromEmbedded(GOBCowNames, pokedex.MetadataFpath(MetadataRoot, idx))
+			},
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := pokesay.WriteNames(os.Stdout, list, format, pokesay.TerminalWidth()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runList prints all available names, or categories, e.g. `pokesay list names -c shiny`
+func runList(set *getopt.Set, argv []string) {
+	set.BoolLong("help", 'h', "display this help message")
+	category := set.StringLong("category", 'c', "", "only list the names that have a sprite in a specific category")
+	format := set.StringLong("format", 'F', "columns", "the output format, one of: "+strings.Join(pokesay.ListFormats, ", "))
+	params := parseArgs(set, argv)
+	if len(params) == 1 && params[0] == "names" {
+		runListNames(*category, *format)
+	} else if len(params) == 1 && params[0] == "categories" {
+		runListCategories(*format)
+	} else {
+		printUsage(set, os.Stderr)
+		os.Exit(1)
+	}
+}
+
+// runBrowse opens the interactive pokedex browser
+func runBrowse(set *getopt.Set, argv []string) {
+	set.BoolLong("help", 'h', "display this help message")
+	parseArgs(set, argv)
+	err := pokesay.Browse(
 		pokedex.ReadStructFromBytes[map[string][]int](GOBAllNames),
+		pokedex.ReadStructFromBytes[[]string](GOBCategoryKeys),
+		GOBCowNames, MetadataRoot,
+		GOBCowData, CowDataRoot,
 	)
-	fmt.Printf("%s\n%d %s\n", strings.Join(names, " "), len(names), "total names")
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
-// GenerateNames returns a list of names to print
-// - If the japanese name flag is set, it returns both the english and japanese names
-// - Otherwise, it returns just the english name
-func GenerateNames(metadata pokedex.PokemonMetadata, args pokesay.Args) []string {
-	if args.JapaneseName {
-		return []string{
-			metadata.Name,
-			fmt.Sprintf("%s (%s)", metadata.JapaneseName, metadata.JapanesePhonetic),
-		}
-	} else {
-		return []string{metadata.Name}
+// runCompletion prints a shell completion script, e.g. `pokesay completion bash`
+// - The options are generated from the flags of the say command
+// - The pokemon names & categories are read from the embedded filesystem, so that the completions
+// always match the pokemon in this binary
+func runCompletion(set *getopt.Set, argv []string) {
+	set.BoolLong("help", 'h', "display this help message")
+	shells := parseArgs(set, argv)
+	if len(shells) != 1 {
+		printUsage(set, os.Stderr)
+		os.Exit(1)
+	}
+	values := map[string][]string{
+		"name":            pokesay.ListNames(pokedex.ReadStructFromBytes[map[string][]int](GOBAllNames)),
+		"category":        pokedex.ReadStructFromBytes[[]string](GOBCategoryKeys),
+		"animate":         pokedex.GatherMapKeys(pokesay.Animations),
+		"width":           {"auto"},
+		"format":          pokesay.ListFormats,
+		"wrap":            pokesay.WrapModes,
+		"align":           pokesay.Alignments,
+		"sprite-align":    pokesay.SpriteAlignments,
+		"sprite-align-to": pokesay.SpriteAlignTargets,
+		"info-position":   pokesay.InfoPositions,
+		"lang":            pokedex.Languages,
+		"filter":          pokesay.NamedColourFilters,
+		"background":      pokesay.Backgrounds,
+	}
+
+	sayFlags := getopt.New()
+	defineFlags(sayFlags, true)
+	options := make([]pokesay.CompletionOption, 0)
+	sayFlags.VisitAll(func(o getopt.Option) {
+		options = append(options, pokesay.CompletionOption{
+			Short:  o.ShortName(),
+			Long:   o.LongName(),
+			IsFlag: o.IsFlag(),
+			Values: values[o.LongName()],
+		})
+	})
+
+	script, err := pokesay.GenerateCompletion(shells[0], options, commandNames())
+	if err != nil {
+		log.Fatal(err)
 	}
+	fmt.Print(script)
 }
 
-// runPrintByName prints a pokemon matched by a name
+// chooseByName chooses a pokemon matched by a name
 // The name must match the lowercase name of the pokemon (TODO: improve this behaviour)
 // - This reads a struct of {name -> metadata indexes} from the embedded filesystem
 // - It matches the name to a metadata index, loads the corresponding metadata file, and then chooses a random entry
-// - Finally, it prints the pokemon
-func runPrintByName(args pokesay.Args) {
-	t := timer.NewTimer("runPrintByName", true)
+func chooseByName(args pokesay.Args, nameToken string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	t := timer.NewTimer("chooseByName", true)
 
 	names := pokedex.ReadStructFromBytes[map[string][]int](GOBAllNames)
 	t.Mark("read name struct")
 
-	metadata, final := pokesay.ChooseByName(names, args.NameToken, GOBCowNames, MetadataRoot)
+	metadata, final, err := pokesay.ChooseByName(names, nameToken, GOBCowNames, MetadataRoot, args.Filter)
 	t.Mark("find/read metadata")
 
-	pokesay.Print(args, final.EntryIndex, GenerateNames(metadata, args), final.Categories, GOBCowData)
-	t.Mark("print")
-
 	t.Stop()
 	t.PrintJson()
+	return metadata, final, err
 }
 
-// runPrintByCategory prints a pokemon matched by a category
+// chooseByCategory chooses a pokemon matched by a category
 // - This loads a GOB file containing a pokemon "category" search struct from the embedded filesystem
 // - It chooses a random category file from the corresponding category directory
 // - It reads the category file and chooses a random pokemon from the category
@@ -158,91 +552,264 @@ func runPrintByName(args pokesay.Args) {
 //   - # this means that pokemon that are in the same category multiple times will be chosen more often
 //
 // - It reads the metadata file of the chosen pokemon and chooses the corresponding entry from the category search
-// - Finally, it prints the pokemon
-func runPrintByCategory(args pokesay.Args) {
-	t := timer.NewTimer("runPrintByCategory", true)
+func chooseByCategory(args pokesay.Args) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	t := timer.NewTimer("chooseByCategory", true)
 
 	dirPath := pokedex.CategoryDirpath(CategoryRoot, args.Category)
 	dir, _ := GOBCategories.ReadDir(dirPath)
-	metadata, final := pokesay.ChooseByCategory(args.Category, dir, GOBCategories, CategoryRoot, GOBCowNames, MetadataRoot)
-
-	pokesay.Print(args, final.EntryIndex, GenerateNames(metadata, args), final.Categories, GOBCowData)
-	t.Mark("print")
+	metadata, final, err := pokesay.ChooseByCategory(args.Category, dir, GOBCategories, CategoryRoot, GOBCowNames, MetadataRoot, args.Filter)
+	t.Mark("find/read metadata")
 
 	t.Stop()
 	t.PrintJson()
+	return metadata, final, err
 }
 
-// runPrintByNameAndCategory prints a pokemon matched by a name and category
+// chooseByNameAndCategory chooses a pokemon matched by a name and category
 // - This reads a struct of {name -> metadata indexes} from the embedded filesystem
 // - It matches the name to a metadata index, loads the corresponding metadata file, and then randomly chooses an entry that matches the category
-// - Finally, it prints the pokemon
-func runPrintByNameAndCategory(args pokesay.Args) {
-	t := timer.NewTimer("runPrintByNameAndCategory", true)
+func chooseByNameAndCategory(args pokesay.Args, nameToken string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	t := timer.NewTimer("chooseByNameAndCategory", true)
 
 	names := pokedex.ReadStructFromBytes[map[string][]int](GOBAllNames)
 	t.Mark("read name struct")
 
-	metadata, final := pokesay.ChooseByNameAndCategory(names, args.NameToken, GOBCowNames, MetadataRoot, args.Category)
+	metadata, final, err := pokesay.ChooseByNameAndCategory(names, nameToken, GOBCowNames, MetadataRoot, args.Category, args.Filter)
 	t.Mark("find/read metadata")
 
-	pokesay.Print(args, final.EntryIndex, GenerateNames(metadata, args), final.Categories, GOBCowData)
-	t.Mark("print")
-
 	t.Stop()
 	t.PrintJson()
+	return metadata, final, err
 }
 
-// runPrintRandom prints a random pokemon
+// chooseRandom chooses a random pokemon
 // - This loads a specific GOB file from the embedded filesystem that contains the number of pokemon
 // - generates a random number between 0 and the number of pokemon
 // - reads the metadata file of at `<index>.metadata` as a PokemonMetadata struct
-// - chooses a random entry from the metadata file
-// - finally prints the pokemon
-func runPrintRandom(args pokesay.Args) {
-	t := timer.NewTimer("runPrintRandom", true)
-	choice := pokesay.RandomInt(pokedex.ReadIntFromBytes(GOBTotal))
-	t.Mark("choose index")
-	metadata := pokedex.ReadMetadataFromEmbedded(GOBCowNames, pokedex.MetadataFpath(MetadataRoot, choice))
-	t.Mark("read metadata")
-
-	final := metadata.Entries[pokesay.RandomInt(len(metadata.Entries))]
+// - chooses a random entry from the metadata file that fits the size limits
+func chooseRandom(args pokesay.Args) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	t := timer.NewTimer("chooseRandom", true)
+
+	metadata, final, err := pokesay.ChooseRandom(GOBTotal, GOBCowNames, MetadataRoot, args.Filter)
 	t.Mark("choose entry")
 
-	pokesay.Print(args, final.EntryIndex, GenerateNames(metadata, args), final.Categories, GOBCowData)
+	t.Stop()
+	t.PrintJson()
+	return metadata, final, err
+}
+
+// choosePokemon chooses a pokemon using the given name (if any), and the category from the args (if any)
+// - An error is returned if no pokemon can be found (see pokesay.ErrNotFound)
+func choosePokemon(args pokesay.Args, nameToken string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	if nameToken != "" && args.Category != "" {
+		return chooseByNameAndCategory(args, nameToken)
+	} else if nameToken != "" {
+		return chooseByName(args, nameToken)
+	} else if args.Category != "" {
+		return chooseByCategory(args)
+	}
+	return chooseRandom(args)
+}
+
+// nameTokenAt returns the i-th name given with -n/--name, or an empty string if there isn't one
+func nameTokenAt(args pokesay.Args, i int) string {
+	if i < len(args.NameTokens) {
+		return args.NameTokens[i]
+	}
+	return ""
+}
+
+// runPrint chooses a single pokemon, and prints it along with the text from STDIN
+func runPrint(args pokesay.Args) {
+	t := timer.NewTimer("runPrint", true)
+
+	metadata, final, err := choosePokemon(args, nameTokenAt(args, 0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	args.SpriteWidth = final.Width
+	args.HeadColumn = pokesay.SpriteOffset(args, final.Width) + final.HeadColumn
+	t.Mark("choose")
+
+	pokedex.Check(pokesay.Print(args, final.EntryIndex, pokesay.NewPokemonInfo(metadata, final, args.InfoDimensions), GOBCowData))
 	t.Mark("print")
 
 	t.Stop()
 	t.PrintJson()
 }
 
-func main() {
-	args := parseFlags()
-	// if the -h/--help flag is set, print usage and exit
-	if args.Help {
-		getopt.Usage()
-		return
+// runPrintScene chooses multiple pokemon, and prints them side by side
+// - A pokemon is chosen for each name given with -n/--name
+// - If --count is larger than the number of names, then the rest are chosen by category, or randomly
+func runPrintScene(args pokesay.Args) {
+	t := timer.NewTimer("runPrintScene", true)
+
+	n := args.Count
+	if len(args.NameTokens) > n {
+		n = len(args.NameTokens)
 	}
-	if args.Verbose {
-		fmt.Println("Verbose output enabled")
-		timer.DEBUG = true
+	pokemon := make([]pokesay.ScenePokemon, 0, n)
+	for i := 0; i < n; i++ {
+		metadata, final, err := choosePokemon(args, nameTokenAt(args, i))
+		if err != nil {
+			log.Fatal(err)
+		}
+		pokemon = append(pokemon, pokesay.ScenePokemon{
+			EntryIndex: final.EntryIndex,
+			HeadColumn: final.HeadColumn,
+			Width:      final.Width,
+			Info:       pokesay.NewPokemonInfo(metadata, final, args.InfoDimensions),
+		})
 	}
+	t.Mark("choose")
 
-	t := timer.NewTimer("main", true)
+	pokedex.Check(pokesay.PrintScene(args, pokemon, GOBCowData))
+	t.Mark("print")
+
+	t.Stop()
+	t.PrintJson()
+}
+
+// runSay chooses one or more pokemon, and prints them along with the text from STDIN
+// - The old list flags are still supported, i.e. `pokesay -l` & `pokesay -L`
+func runSay(set *getopt.Set, argv []string) {
+	f := defineFlags(set, true)
+	message := parseArgs(set, argv)
+	args := f.args()
 
 	if args.ListCategories {
-		runListCategories()
+		runListCategories(args.ListFormat)
+		return
 	} else if args.ListNames {
-		runListNames()
-	} else if args.NameToken != "" && args.Category != "" {
-		runPrintByNameAndCategory(args)
-	} else if args.NameToken != "" {
-		runPrintByName(args)
-	} else if args.Category != "" {
-		runPrintByCategory(args)
+		runListNames(args.Category, args.ListFormat)
+		return
+	}
+
+	args.Background = resolveBackground(args.Background)
+	input, err := pokesay.ResolveMessage(message, *f.file, *f.fortune, os.Stdin, func() []string { return readFortunes(*f.fortuneFile) })
+	if err != nil {
+		log.Fatal(err)
+	}
+	args.Input = input
+	var output bytes.Buffer
+	if *f.pager {
+		args.Output = &output
+	}
+	// a single pokemon with --split speaks each message in turn, like a scene
+	if args.Count > 1 || len(args.NameTokens) > 1 || args.SplitDelimiter != "" {
+		runPrintScene(args)
 	} else {
-		runPrintRandom(args)
+		runPrint(args)
+	}
+	if *f.pager {
+		pokedex.Check(pokesay.Page(output.Bytes()))
+	}
+}
+
+// runShow prints a pokemon chosen by name, without reading STDIN or drawing a speech bubble
+func runShow(set *getopt.Set, argv []string) {
+	f := defineFlags(set, false)
+	params := parseArgs(set, argv)
+	if len(params) != 1 {
+		printUsage(set, os.Stderr)
+		os.Exit(1)
+	}
+	args := f.args()
+
+	args.Background = resolveBackground(args.Background)
+	metadata, final, err := choosePokemon(args, params[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	args.SpriteWidth = final.Width
+	pokesay.PrintPokemon(args, final.EntryIndex, pokesay.NewPokemonInfo(metadata, final, args.InfoDimensions), GOBCowData)
+}
+
+// runInfo prints a pokedex card for a pokemon, with every form & category
+// - If multiple pokemon have the same name, then a card is printed for each of them
+func runInfo(set *getopt.Set, argv []string) {
+	set.BoolLong("help", 'h', "display this help message")
+	width := set.StringLong("width", 'w', "auto", "the width to lay out the sprites in, or 'auto' to fit the terminal width")
+	unicodeBorders := set.BoolLong("unicode-borders", 'u', "use unicode characters to draw the border around the card")
+	params := parseArgs(set, argv)
+	if len(params) != 1 {
+		printUsage(set, os.Stderr)
+		os.Exit(1)
+	}
+
+	cardWidth := pokesay.TerminalWidth()
+	if *width != "auto" {
+		cardWidth, _ = parseWidth(*width)
+	}
+	names := pokedex.ReadStructFromBytes[map[string][]int](GOBAllNames)
+	indexes, ok := names[params[0]]
+	if !ok {
+		log.Fatalf("cannot find pokemon by name '%s'", params[0])
+	}
+
+	for i, idx := range indexes {
+		metadata := pokedex.ReadMetadataFromEmbedded(GOBCowNames, pokedex.MetadataFpath(MetadataRoot, idx))
+		sprites := make([][]byte, 0, len(metadata.Entries))
+		for _, entry := range metadata.Entries {
+			sprites = append(sprites, pokedex.ReadPokemonCow(GOBCowData, pokedex.EntryFpath(CowDataRoot, entry.EntryIndex)))
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		for _, line := range pokesay.RenderInfoCard(metadata, sprites, cardWidth, pokesay.DetermineBoxChars(*unicodeBorders)) {
+			fmt.Println(line)
+		}
+	}
+}
+
+// runServe serves pokemon over HTTP, e.g. `curl 'localhost:8080/?name=pikachu&text=hello'`
+// - The query parameters are: name, category, text, width, title, footer, japanese-name, info-border & unicode-borders
+// - The text can also be sent as the body of a POST request
+func runServe(set *getopt.Set, argv []string) {
+	set.BoolLong("help", 'h', "display this help message")
+	addr := set.StringLong("addr", 0, ":8080", "the address to listen on")
+	parseArgs(set, argv)
+
+	index := pokedex.ReadStructFromBytes[[]pokedex.CategoryDimension](GOBCategoryDimensions)
+	http.Handle("/", pokesay.ServeHandler(index, choosePokemon, GOBCowData))
+
+	log.Printf("serving pokemon on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// printUsage prints the usage of a command. The usage of `pokesay` also lists all of the commands
+func printUsage(set *getopt.Set, w io.Writer) {
+	set.PrintUsage(w)
+	if set.Program() != "pokesay" {
+		return
+	}
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-12s%s\n", c.Name, c.Description)
+	}
+	fmt.Fprintln(w, "\nRun 'pokesay <command> -h' for the usage of a command")
+	fmt.Fprintln(w, "Put a message that starts with a command name after --, e.g. 'pokesay -- show must go on'")
+}
+
+func main() {
+	t := timer.NewTimer("main", true)
+
+	// the command is the first argument, otherwise `pokesay [flags]` runs the say command
+	argv := os.Args[1:]
+	run, set := runSay, getopt.New()
+	set.SetProgram("pokesay")
+	set.SetParameters("[message...]")
+	if len(argv) > 0 {
+		for _, c := range commands() {
+			if c.Name == argv[0] {
+				run, set = c.Run, newCommandSet(c)
+				argv = argv[1:]
+				break
+			}
+		}
 	}
+	set.SetUsage(func() { printUsage(set, os.Stderr) })
+	run(set, append([]string{set.Program()}, argv...))
 	t.Mark("op")
 
 	t.Stop()
@@ -18,7 +18,6 @@ type CowBuildArgs struct {
 	FromDir  string
 	ToDir    string
 	SkipDirs []string
-	Padding  int
 	Debug    bool
 }
 
@@ -26,14 +25,13 @@ func parseArgs() CowBuildArgs {
 	fromDir := flag.String("from", ".", "from dir")
 	toDir := flag.String("to", ".", "to dir")
 	skipDirs := flag.String("skip", "'[\"resources\"]'", "JSON array of dir patterns to skip converting")
-	padding := flag.Int("padding", 2, "the number of spaces to pad from the left")
 	debug := flag.Bool("debug", DEBUG, "show debug logs")
 
 	flag.Parse()
 
 	DEBUG = *debug
 
-	args := CowBuildArgs{FromDir: *fromDir, ToDir: *toDir, Padding: *padding}
+	args := CowBuildArgs{FromDir: *fromDir, ToDir: *toDir}
 	json.Unmarshal([]byte(*skipDirs), &args.SkipDirs)
 
 	if DEBUG {
@@ -53,7 +51,7 @@ func main() {
 	fmt.Println("Converting PNGs -> cowfiles")
 	pbar := bin.NewProgressBar(len(fpaths))
 	for _, f := range fpaths {
-		pokedex.ConvertPngToCow(args.FromDir, f, args.ToDir, args.Padding)
+		pokedex.ConvertPngToCow(args.FromDir, f, args.ToDir)
 		pbar.Add(1)
 	}
 	fmt.Println("Finished converting", len(fpaths), "pokesprite PNGs", "-> cowfiles")
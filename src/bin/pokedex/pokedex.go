@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
@@ -24,6 +25,8 @@ type PokedexArgs struct {
 	ToDataSubDir      string
 	ToMetadataSubDir  string
 	ToTotalFname      string
+	FromFortunesDir   string
+	SizeCategories    []pokedex.SizeCategory
 }
 
 type PokedexPaths struct {
@@ -48,6 +51,12 @@ func parseArgs() PokedexArgs {
 	toDataSubDir := flag.String("toDataSubDir", "cows/", "dir to write all binary (image) data to")
 	toMetadataSubDir := flag.String("toMetadataSubDir", "metadata/", "dir to write all binary (metadata) data to")
 	toTotalFname := flag.String("toTotalFname", "total.txt", "file to write the number of available entries to")
+	fromFortunesDir := flag.String("fromFortunes", "build/fortunes/", "dir of fortune files (separated by '%' lines) to embed")
+	sizeCategories := flag.String(
+		"sizeCategories",
+		pokedex.StructToJSON(pokedex.DefaultSizeCategories),
+		"JSON array of size categories, ordered from smallest to largest",
+	)
 	debug := flag.Bool("debug", false, "show debug logs")
 
 	flag.Parse()
@@ -59,8 +68,12 @@ func parseArgs() PokedexArgs {
 		ToDataSubDir:      normaliseRelativeDir(*toDataSubDir),
 		ToMetadataSubDir:  normaliseRelativeDir(*toMetadataSubDir),
 		ToTotalFname:      *toTotalFname,
+		FromFortunesDir:   normaliseRelativeDir(*fromFortunesDir),
 		Debug:             *debug,
 	}
+	err := json.Unmarshal([]byte(*sizeCategories), &args.SizeCategories)
+	pokedex.Check(err)
+
 	if args.Debug {
 		fmt.Printf("%+v\n", args)
 	}
@@ -78,6 +91,9 @@ func mkDirs(dirPaths []string) {
 // - The "category" struct
 //   - contains category information, and the index of the corresponding metadata file
 //
+// - The "category dimensions" struct
+//   - groups the categories into dimensions, e.g. size, gen & variant
+//
 // - The "metadata" files
 //   - named like 1.metadata, contains pokemon info like name, categories, japanese name
 //
@@ -86,6 +102,9 @@ func mkDirs(dirPaths []string) {
 //
 // - The "total" file
 //   - contains the total number of pokemon files, used for random selection
+//
+// - The "fortunes" file
+//   - contains the quotes that are printed when no text is given, as gzipped text
 func main() {
 	args := parseArgs()
 	paths := NewPokedexPaths(args)
@@ -106,7 +125,8 @@ func main() {
 		data, err := os.ReadFile(fpath)
 		pokedex.Check(err)
 
-		pokedex.WriteBytesToFile(data, pokedex.EntryFpath(paths.EntryDirPath, i), true)
+		// the sprites are stored unpadded, so that they can be aligned & indented when printed
+		pokedex.WriteBytesToFile(pokedex.TrimCowfile(data), pokedex.EntryFpath(paths.EntryDirPath, i), true)
 		pbar.Add(1)
 	}
 
@@ -118,7 +138,7 @@ func main() {
 	i := 0
 	pbar = bin.NewProgressBar(len(pokemonNames))
 	for key, name := range pokemonNames {
-		metadata := pokedex.CreateNameMetadata(i, key, name, args.FromDir, cowfileFpaths)
+		metadata := pokedex.CreateNameMetadata(i, key, name, args.FromDir, cowfileFpaths, args.SizeCategories)
 		pokedex.WriteStructToFile(metadata, pokedex.MetadataFpath(paths.MetadataDirPath, i))
 		pokemonMetadata = append(pokemonMetadata, *metadata)
 		uniqueNames[name.Slug] = append(uniqueNames[name.Slug], i)
@@ -132,14 +152,24 @@ func main() {
 	fmt.Println("- Writing categories to file")
 	categories := pokedex.CreateCategoryStruct(args.FromDir, pokemonMetadata, args.Debug)
 	pokedex.WriteStructToFile(categories, "build/assets/category_keys.txt")
+	pokedex.WriteStructToFile(
+		pokedex.CreateDimensionIndex(pokemonMetadata, args.SizeCategories),
+		"build/assets/category_dimensions.txt",
+	)
 
 	fmt.Println("- Writing total metadata to file")
 	pokedex.WriteIntToFile(len(pokemonMetadata), paths.TotalFpath)
 
+	fmt.Println("- Writing fortunes to file")
+	fortunes, err := pokedex.ReadFortuneFiles(args.FromFortunesDir)
+	pokedex.Check(err)
+	pokedex.WriteBytesToFile([]byte(strings.Join(fortunes, "\n%\n")), "build/assets/fortunes.txt", true)
+
 	fmt.Println("✓ Complete! Indexed", len(cowfileFpaths), "total cowfiles")
 	fmt.Println("wrote", i, "names to", "build/assets/names.txt")
 
 	fmt.Println("✓ Wrote gzipped metadata to", paths.MetadataDirPath)
 	fmt.Println("✓ Wrote gzipped cowfiles to", paths.EntryDirPath)
 	fmt.Println("✓ Wrote 'total' metadata to", paths.TotalFpath, len(pokemonMetadata))
+	fmt.Println("✓ Wrote", len(fortunes), "gzipped fortunes to", "build/assets/fortunes.txt")
 }
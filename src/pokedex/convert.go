@@ -97,17 +97,43 @@ func stripEmptyLines(cowfile []string) []string {
 	return converted
 }
 
-func padLeft(cowfile []byte, n int) []string {
-	converted := make([]string, 0)
-	lines := strings.Split(string(cowfile), "\n")
+// TrimCowfile removes the blank lines from the top & bottom of a cowfile, and the spaces to the left
+// of the sprite that are common to every line, so that the sprite can be positioned when it's printed
+// - A line is blank if it has no visible cells, see SpriteHeadColumn
+// - The trimmed cowfile always ends with a colour reset
+func TrimCowfile(cowfile []byte) []byte {
+	text := strings.TrimSuffix(strings.TrimRight(string(cowfile), "\n"), strings.TrimSuffix(COLOUR_RESET, "\n"))
+	lines := strings.Split(text, "\n")
+
+	first, last, padding := -1, -1, -1
+	for i, line := range lines {
+		if start, _ := visibleColumns(line); start < 0 {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+		if n := countLineLeftPadding(line); padding < 0 || n < padding {
+			padding = n
+		}
+	}
+	if first < 0 {
+		return []byte(COLOUR_RESET)
+	}
 
-	for _, line := range lines {
-		converted = append(converted, strings.Repeat(" ", n)+line)
+	trimmed := make([]string, 0, last-first+1)
+	for _, line := range lines[first : last+1] {
+		n := countLineLeftPadding(line)
+		if n > padding {
+			n = padding
+		}
+		trimmed = append(trimmed, line[n:])
 	}
-	return converted
+	return []byte(strings.Join(trimmed, "\n") + COLOUR_RESET)
 }
 
-func ConvertPngToCow(sourceDirpath string, sourceFpath string, destDirpath string, extraPadding int) {
+func ConvertPngToCow(sourceDirpath string, sourceFpath string, destDirpath string) {
 	destDir := filepath.Join(
 		destDirpath,
 		// strip the root "source dirpath" from the source path
@@ -134,10 +160,10 @@ func ConvertPngToCow(sourceDirpath string, sourceFpath string, destDirpath strin
 	defer ostream.Close()
 	writer := bufio.NewWriter(ostream)
 
-	final := stripEmptyLines(padLeft(converted, extraPadding))
-
-	// Join all of the lines back together, add colour reset sequence at the end
-	_, err = writer.WriteString(strings.Join(final, "\n") + COLOUR_RESET)
+	// The sprites are stored without any padding, they're indented when they're printed.
+	// Join all of the lines back together, with a colour reset sequence at the end
+	final := TrimCowfile([]byte(strings.Join(stripEmptyLines(strings.Split(string(converted), "\n")), "\n")))
+	_, err = writer.Write(final)
 	Check(err)
 
 	writer.Flush()
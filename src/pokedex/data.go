@@ -10,17 +10,16 @@ import (
 )
 
 //	{
+//	  "idx": "006",
 //	  "name": { "eng": "Charizard", "chs": "喷火龙", "jpn": "リザードン", "jpn_ro": "Lizardon" }
 //	  "slug": { "eng": "charizard",                  "jpn": "riza-don",   "jpn_ro": "lizardon" }
 //	}
 //
-// Out of all these names, we want the name.jpn, name.jpn_ro, slug.eng,
+// Out of all these names, we want every name (e.g. name.eng, name.chs & name.jpn), slug.jpn,
+// slug.eng, and the idx (pokedex number)
 type DataEntry struct {
-	Name struct {
-		Eng    string `json:"eng"`
-		Jpn    string `json:"jpn"`
-		Jpn_ro string `json:"jpn_ro"`
-	} `json:"name"`
+	Idx  string            `json:"idx"`
+	Name map[string]string `json:"name"` // the name in each language, e.g. {"eng": "Charizard", "chs": "喷火龙"}
 	Slug struct {
 		Eng    string `json:"eng"`
 		Jpn    string `json:"jpn"`
@@ -33,14 +32,32 @@ type PokemonName struct {
 	Japanese         string
 	JapanesePhonetic string
 	Slug             string
+	Dex              string            // the national pokedex number, e.g. "025"
+	Names            map[string]string // the name in each language, by language code (see LanguageCodes)
 }
 
+var (
+	// The language code of each name in the source data, e.g. the "chs" name is Chinese (zh).
+	// Names in any other languages are dropped, as they can't be selected with --lang
+	LanguageCodes map[string]string = map[string]string{"eng": "en", "jpn": "ja", "jpn_ro": "ja-ro", "chs": "zh"}
+	// The language codes of the names that are kept (see LanguageCodes), in the order that they're documented
+	Languages []string = []string{"en", "ja", "ja-ro", "zh"}
+)
+
 func NewPokemonName(entry DataEntry) *PokemonName {
+	names := make(map[string]string)
+	for language, name := range entry.Name {
+		if code, ok := LanguageCodes[language]; ok {
+			names[code] = name
+		}
+	}
 	return &PokemonName{
-		English:          entry.Name.Eng,
-		Japanese:         entry.Name.Jpn,
+		English:          entry.Name["eng"],
+		Japanese:         entry.Name["jpn"],
 		JapanesePhonetic: entry.Slug.Jpn,
 		Slug:             entry.Slug.Eng,
+		Dex:              entry.Idx,
+		Names:            names,
 	}
 }
 
@@ -60,7 +77,7 @@ func ReadNames(fpath string) map[string]PokemonName {
 		if jsonErr != nil {
 			fmt.Println(jsonErr)
 		}
-		entries[strings.ToLower(entry.Name.Eng)] = *NewPokemonName(entry)
+		entries[strings.ToLower(entry.Name["eng"])] = *NewPokemonName(entry)
 	}
 	if err := scanner.Err(); err != nil {
 		log.Fatal(err)
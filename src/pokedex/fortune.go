@@ -0,0 +1,68 @@
+package pokedex
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ParseFortunes splits the contents of a fortune file into fortunes
+// - Fortunes are separated by lines that only contain a "%" (the format used by fortune(6))
+// - Any empty fortunes are skipped, and carriage returns (e.g. from windows line endings) are removed
+func ParseFortunes(data string) []string {
+	fortunes := make([]string, 0)
+	lines := make([]string, 0)
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if line != "%" {
+			lines = append(lines, line)
+			continue
+		}
+		if fortune := strings.Trim(strings.Join(lines, "\n"), "\n"); fortune != "" {
+			fortunes = append(fortunes, fortune)
+		}
+		lines = lines[:0]
+	}
+	if fortune := strings.Trim(strings.Join(lines, "\n"), "\n"); fortune != "" {
+		fortunes = append(fortunes, fortune)
+	}
+	return fortunes
+}
+
+// ReadFortuneFiles reads the fortunes from a fortune file, or from every fortune file in a directory
+// - Hidden files, and the ".dat" index files created by strfile(1), are skipped
+func ReadFortuneFiles(fpath string) ([]string, error) {
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return nil, err
+	}
+	fpaths := []string{fpath}
+	if info.IsDir() {
+		entries, err := os.ReadDir(fpath)
+		if err != nil {
+			return nil, err
+		}
+		fpaths = fpaths[:0]
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || path.Ext(entry.Name()) == ".dat" {
+				continue
+			}
+			fpaths = append(fpaths, path.Join(fpath, entry.Name()))
+		}
+		sort.Strings(fpaths)
+	}
+
+	fortunes := make([]string, 0)
+	for _, f := range fpaths {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		fortunes = append(fortunes, ParseFortunes(string(data))...)
+	}
+	if len(fortunes) == 0 {
+		return nil, fmt.Errorf("no fortunes found in '%s'", fpath)
+	}
+	return fortunes, nil
+}
@@ -10,29 +10,29 @@ import (
 type PokemonEntryMapping struct {
 	EntryIndex int
 	Categories []string
+	Width      int // the rendered width of the cowfile, in terminal columns
+	Height     int // the rendered height of the cowfile, in lines
+	HeadColumn int // the column of the top of the pokemon's head, that a speech bubble's tether points at
+	// the category of the cowfile in each dimension, e.g. {"size": "small", "gen": "gen8", "variant": "shiny"}
+	Dimensions map[string]string
 }
 
 type PokemonMetadata struct {
 	Name             string
 	JapaneseName     string
 	JapanesePhonetic string
+	Dex              string            // the national pokedex number, e.g. "025"
+	Names            map[string]string // the name in each language, by language code (see LanguageCodes)
 	Entries          []PokemonEntryMapping
 }
 
-func NewMetadata(name string, japaneseName string, japanesePhonetic string, entryMap map[int][][]string) *PokemonMetadata {
-
-	entries := make([]PokemonEntryMapping, 0)
-
-	for idx, categories := range entryMap {
-		for _, category := range categories {
-			entries = append(entries, PokemonEntryMapping{idx, category})
-		}
-	}
-
+func NewMetadata(name string, japaneseName string, japanesePhonetic string, dex string, names map[string]string, entries []PokemonEntryMapping) *PokemonMetadata {
 	return &PokemonMetadata{
 		Name:             name,
 		JapaneseName:     japaneseName,
 		JapanesePhonetic: japanesePhonetic,
+		Dex:              dex,
+		Names:            names,
 		Entries:          entries,
 	}
 }
@@ -14,6 +14,9 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
 )
 
 func Check(e error) {
@@ -47,6 +50,16 @@ func GatherMapKeys[T any](m map[string]T) []string {
 	return keys
 }
 
+// ContainsString returns true if the slice contains the string s
+func ContainsString(slice []string, s string) bool {
+	for _, el := range slice {
+		if el == s {
+			return true
+		}
+	}
+	return false
+}
+
 func ReadStructFromBytes[T any](data []byte) T {
 	var d T
 	gob.NewDecoder(bytes.NewBuffer(data)).Decode(&d)
@@ -130,23 +143,61 @@ func Decompress(data []byte) []byte {
 	return resB.Bytes()
 }
 
-func CreateNameMetadata(idx int, key string, name PokemonName, rootDir string, fpaths []string) *PokemonMetadata {
-	entryCategories := make(map[int][][]string, 0)
+// SizeCategory is a named bucket for pokemon sprites, based on their rendered height.
+// A sprite belongs to the first size category that it fits within, a MaxHeight of 0 means that
+// there is no upper limit
+type SizeCategory struct {
+	Name      string `json:"name"`
+	MaxHeight int    `json:"maxHeight"`
+}
+
+// CategoryDimension is a group of categories that are alternatives to each other, e.g. the "gen"
+// dimension contains gen7x & gen8
+type CategoryDimension struct {
+	Name   string
+	Values []string
+}
+
+var (
+	DefaultSizeCategories []SizeCategory = []SizeCategory{
+		{Name: "small", MaxHeight: 12},
+		{Name: "medium", MaxHeight: 18},
+		{Name: "big"},
+	}
+	// The size dimension is assigned from the size categories, and the other dimensions are assigned
+	// from the directories of the cowfile path, in order, e.g. gen8/shiny/female/pikachu.cow
+	SizeDimension       string   = "size"
+	DirectoryDimensions []string = []string{"gen", "variant", "form"}
+)
+
+func CreateNameMetadata(idx int, key string, name PokemonName, rootDir string, fpaths []string, sizes []SizeCategory) *PokemonMetadata {
+	entries := make([]PokemonEntryMapping, 0)
 
 	for i, fpath := range fpaths {
 		basename := strings.TrimPrefix(fpath, rootDir)
 		if strings.Contains(basename, strings.ToLower(name.Slug)) {
 			data, err := os.ReadFile(fpath)
 			Check(err)
-			cats := createCategories(strings.TrimPrefix(fpath, rootDir), data)
-			entryCategories[i] = append(entryCategories[i], cats)
+			// measure the sprite as it's stored, i.e. without any padding
+			data = TrimCowfile(data)
+			width, height := CowfileDimensions(data)
+			entries = append(entries, PokemonEntryMapping{
+				EntryIndex: i,
+				Categories: createCategories(strings.TrimPrefix(fpath, rootDir), sizeCategory(height, sizes)),
+				Dimensions: createDimensions(strings.TrimPrefix(fpath, rootDir), sizeCategory(height, sizes)),
+				Width:      width,
+				Height:     height,
+				HeadColumn: SpriteHeadColumn(data),
+			})
 		}
 	}
 	return NewMetadata(
 		name.English,
 		name.Japanese,
 		name.JapanesePhonetic,
-		entryCategories,
+		name.Dex,
+		name.Names,
+		entries,
 	)
 }
 
@@ -168,20 +219,165 @@ func CreateCategoryStruct(rootDir string, metadata []PokemonMetadata, debug bool
 	return GatherMapKeys(uniqueCategories)
 }
 
-func createCategories(fpath string, data []byte) []string {
+func createCategories(fpath string, size string) []string {
 	parts := strings.Split(fpath, "/")
-	height := sizeCategory(len(strings.Split(string(data), "\n")))
 
-	return append([]string{height}, parts[0:len(parts)-1]...)
+	return append([]string{size}, parts[0:len(parts)-1]...)
+}
+
+// createDimensions returns the category of a cowfile in each dimension. Any directories beyond the
+// DirectoryDimensions are only included in the flat list of categories
+func createDimensions(fpath string, size string) map[string]string {
+	parts := strings.Split(fpath, "/")
+	dimensions := map[string]string{SizeDimension: size}
+
+	for i, part := range parts[0 : len(parts)-1] {
+		if i < len(DirectoryDimensions) {
+			dimensions[DirectoryDimensions[i]] = part
+		}
+	}
+	return dimensions
+}
+
+// CreateDimensionIndex returns every category dimension that is used by the metadata entries
+// - The dimensions are ordered by size, and then the DirectoryDimensions
+// - The size values are ordered from smallest to largest, and the other values are sorted
+func CreateDimensionIndex(metadata []PokemonMetadata, sizes []SizeCategory) []CategoryDimension {
+	values := make(map[string]map[string]bool)
+	for _, m := range metadata {
+		for _, entry := range m.Entries {
+			for dimension, value := range entry.Dimensions {
+				if values[dimension] == nil {
+					values[dimension] = make(map[string]bool)
+				}
+				values[dimension][value] = true
+			}
+		}
+	}
+
+	index := make([]CategoryDimension, 0)
+	sizeValues := make([]string, 0)
+	for _, size := range sizes {
+		if values[SizeDimension][size.Name] {
+			sizeValues = append(sizeValues, size.Name)
+		}
+	}
+	if len(sizeValues) > 0 {
+		index = append(index, CategoryDimension{SizeDimension, sizeValues})
+	}
+	for _, dimension := range DirectoryDimensions {
+		if len(values[dimension]) > 0 {
+			index = append(index, CategoryDimension{dimension, GatherMapKeys(values[dimension])})
+		}
+	}
+	return index
 }
 
-func sizeCategory(height int) string {
-	if height <= 13 {
-		return "small"
-	} else if height <= 19 {
-		return "medium"
+// FindDimension returns the name of the dimension that contains a category, or an empty string if
+// the category isn't in any dimension
+func FindDimension(index []CategoryDimension, category string) string {
+	for _, dimension := range index {
+		for _, value := range dimension.Values {
+			if value == category {
+				return dimension.Name
+			}
+		}
+	}
+	return ""
+}
+
+// sizeCategory returns the name of the first size category that a sprite of the given height fits within
+func sizeCategory(height int, sizes []SizeCategory) string {
+	for _, size := range sizes {
+		if size.MaxHeight <= 0 || height <= size.MaxHeight {
+			return size.Name
+		}
+	}
+	log.Fatalf("no size category fits a sprite with height %d, the last category should have no max height", height)
+	return ""
+}
+
+// SpriteHeadColumn returns the column of the top of a sprite (i.e. the pokemon's head), which is
+// the middle of the visible cells on the top-most line that has any
+// - A cell is visible if it has a character other than a space, or a space with a background colour
+// - If the sprite is blank, then 0 is returned
+func SpriteHeadColumn(cowfile []byte) int {
+	for _, line := range strings.Split(string(cowfile), "\n") {
+		if first, last := visibleColumns(line); first >= 0 {
+			return (first + last) / 2
+		}
+	}
+	return 0
+}
+
+// visibleColumns returns the first & last columns of a line that have a visible cell (see
+// SpriteHeadColumn), or -1 & -1 if the line is blank
+func visibleColumns(line string) (int, int) {
+	first, last, column, background := -1, -1, 0, false
+	for i := 0; i < len(line); {
+		if n := EscapeLength(line[i:]); n > 0 {
+			background = sgrBackground(line[i:i+n], background)
+			i += n
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(line[i:])
+		width := uniseg.StringWidth(line[i : i+size])
+		if line[i] != ' ' || background {
+			if first < 0 {
+				first = column
+			}
+			last = column + width - 1
+		}
+		column += width
+		i += size
+	}
+	return first, last
+}
+
+// sgrBackground returns whether a background colour is set after an escape sequence, given whether
+// one was set before it
+func sgrBackground(seq string, background bool) bool {
+	if !strings.HasPrefix(seq, "\033[") || !strings.HasSuffix(seq, "m") {
+		return background
+	}
+	params := strings.Split(seq[2:len(seq)-1], ";")
+	for i := 0; i < len(params); i++ {
+		// colon-separated sub-parameters (e.g. "48:5:16") are a part of the parameter
+		code, err := strconv.Atoi(strings.SplitN(params[i], ":", 2)[0])
+		if err != nil && params[i] != "" {
+			continue
+		}
+		switch {
+		case code == 0 || code == 49:
+			background = false
+		case (code >= 40 && code <= 47) || (code >= 100 && code <= 107):
+			background = true
+		case code == 38 || code == 48 || code == 58:
+			background = background || code == 48
+			// skip the parameters of an extended colour, e.g. "38;5;40" isn't a background colour.
+			// colon-separated colours (e.g. "38:5:40") are already a single parameter
+			if !strings.Contains(params[i], ":") && i+1 < len(params) && params[i+1] == "2" {
+				i += 4
+			} else if !strings.Contains(params[i], ":") {
+				i += 2
+			}
+		}
+	}
+	return background
+}
+
+// CowfileDimensions returns the rendered width & height of a cowfile, i.e. the number of terminal
+// columns taken up by the widest line, and the number of lines
+func CowfileDimensions(cowfile []byte) (int, int) {
+	lines := strings.Split(strings.TrimRight(string(cowfile), "\n"), "\n")
+
+	width := 0
+	for _, line := range lines {
+		if lineWidth := UnicodeStringLength(line); lineWidth > width {
+			width = lineWidth
+		}
 	}
-	return "big"
+	return width, len(lines)
 }
 
 func ReadPokemonCow(embeddedData embed.FS, fpath string) []byte {
@@ -0,0 +1,106 @@
+package pokedex
+
+import (
+	"github.com/rivo/uniseg"
+)
+
+// The states of the escape sequence parser in EscapeLength
+const (
+	escStateStart        = iota // at the ESC byte
+	escStateEscape              // after ESC, e.g. "\033"
+	escStateCSI                 // in a control sequence, e.g. "\033[38;5;196"
+	escStateString              // in an OSC/DCS/SOS/PM/APC string, e.g. "\033]8;;https://..."
+	escStateStringEscape        // after an ESC in a string, which may be the start of the terminator "\033\\"
+	escStateIntermediate        // after the intermediate bytes of an ESC sequence, e.g. "\033("
+)
+
+// EscapeLength returns the length in bytes of the terminal escape sequence at the start of s, or 0
+// if s doesn't start with an escape sequence. An unterminated sequence runs to the end of s.
+// - CSI sequences, e.g. colours "\033[38;5;196m" or cursor movement "\033[2A"
+// - OSC strings terminated by BEL or ST, e.g. hyperlinks "\033]8;;https://example.com\033\\"
+// - DCS, SOS, PM & APC strings terminated by ST
+// - Other ESC sequences, e.g. character set selection "\033(B"
+func EscapeLength(s string) int {
+	state := escStateStart
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch state {
+		case escStateStart:
+			if b != '\033' {
+				return 0
+			}
+			state = escStateEscape
+		case escStateEscape:
+			switch {
+			case b == '[':
+				state = escStateCSI
+			case b == ']' || b == 'P' || b == 'X' || b == '^' || b == '_':
+				state = escStateString
+			case b >= 0x20 && b <= 0x2f:
+				state = escStateIntermediate
+			case b >= 0x30 && b <= 0x7e:
+				return i + 1
+			default:
+				// not a valid escape sequence, so only the ESC is consumed
+				return 1
+			}
+		case escStateCSI:
+			// parameter (0x30-0x3f) & intermediate (0x20-0x2f) bytes, until a final byte (0x40-0x7e)
+			if b >= 0x40 && b <= 0x7e {
+				return i + 1
+			}
+		case escStateString:
+			if b == '\a' {
+				return i + 1
+			} else if b == '\033' {
+				state = escStateStringEscape
+			}
+		case escStateStringEscape:
+			if b == '\\' {
+				return i + 1
+			}
+			state = escStateString
+		case escStateIntermediate:
+			if b >= 0x30 && b <= 0x7e {
+				return i + 1
+			}
+		}
+	}
+	if state == escStateStart {
+		return 0
+	}
+	return len(s)
+}
+
+// UnicodeStringLength returns the width of a string in terminal columns
+// - Escape sequences (see EscapeLength) & other control characters are zero-width
+// - The width of each grapheme cluster is calculated as a whole, so that emoji ZWJ sequences,
+// variation selectors & combining marks are measured correctly
+// - East Asian wide characters (e.g. CJK) are 2 columns wide
+func UnicodeStringLength(s string) int {
+	totalLen := 0
+	for len(s) > 0 {
+		if n := EscapeLength(s); n > 0 {
+			s = s[n:]
+			continue
+		}
+		// find the run of text before the next control character
+		end, ascii := 0, true
+		for end < len(s) && s[end] >= 0x20 && s[end] != 0x7f {
+			ascii = ascii && s[end] < 0x80
+			end++
+		}
+		if end == 0 {
+			s = s[1:]
+			continue
+		}
+		if ascii {
+			// if ascii, then use width of 1. this saves some time
+			totalLen += end
+		} else {
+			totalLen += uniseg.StringWidth(s[:end])
+		}
+		s = s[end:]
+	}
+	return totalLen
+}
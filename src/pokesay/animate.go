@@ -0,0 +1,220 @@
+package pokesay
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+	"golang.org/x/term"
+)
+
+var (
+	// The available animations, and the default frames per second for each of them
+	Animations map[string]int = map[string]int{
+		"type":    30, // types out the speech bubble text, one character per frame
+		"bob":     2,  // bobs the pokemon up and down by a row
+		"sparkle": 4,  // flashes sparkles around the pokemon
+	}
+	SparkleChars   []string = []string{"*", "+", "."}
+	SparkleColours []string = []string{"\033[38;5;226m", "\033[38;5;231m", "\033[38;5;229m"}
+	SparkleCount   int      = 6 // the number of sparkles drawn in each frame
+
+	hideCursorANSI string = "\033[?25l"
+	showCursorANSI string = "\033[?25h"
+	clearLineANSI  string = "\033[K"
+)
+
+// Animate prints a pokemon (and the text from STDIN) with an animation
+// - "type" prints the speech bubble one character at a time, and then the pokemon
+// - "bob" prints the speech bubble, and then continuously moves the pokemon up and down
+// - "sparkle" prints the speech bubble, and then continuously flashes sparkles around the pokemon
+//
+// The looping animations run until interrupted with Ctrl-C. If the output is not a terminal, then
+// nothing is animated, and the output is the same as Print.
+func Animate(args Args, choice int, pokemon PokemonInfo, cows embed.FS) error {
+	var bubble bytes.Buffer
+	if err := printSpeechBubble(&bubble, args.BoxChars, args.input(), args); err != nil {
+		return err
+	}
+	sprite := strings.Split(strings.TrimRight(string(renderSprite(args, choice, cows)), "\n"), "\n")
+	info := RenderInfoBox(args, pokemon)
+
+	w := args.output()
+	if f, ok := w.(*os.File); !ok || !term.IsTerminal(int(f.Fd())) {
+		fmt.Fprintf(w, "%s%s\n", bubble.String(), strings.Join(LayoutPokemon(args, sprite, info), "\n"))
+		return nil
+	}
+
+	fps := args.FPS
+	if fps <= 0 {
+		fps = Animations[args.Animate]
+	}
+	delay := time.Second / time.Duration(fps)
+
+	// stop animating on Ctrl-C, and always leave the terminal with a visible cursor & no colours
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	fmt.Fprint(w, hideCursorANSI)
+	defer fmt.Fprint(w, resetColourANSI, showCursorANSI)
+
+	switch args.Animate {
+	case "type":
+		before, text, after := SplitTypedText(args, bubble.String())
+		fmt.Fprint(w, before)
+		TypeText(w, text, delay, stop)
+		fmt.Fprintf(w, "%s%s\n", after, strings.Join(LayoutPokemon(args, sprite, info), "\n"))
+	case "bob":
+		fmt.Fprint(w, bubble.String())
+		animateFrames(w, func(i int) []string { return LayoutPokemon(args, BobFrame(sprite, i), info) }, delay, stop)
+	case "sparkle":
+		fmt.Fprint(w, bubble.String())
+		animateFrames(w, func(i int) []string { return LayoutPokemon(args, SparkleFrame(sprite), info) }, delay, stop)
+	}
+	return nil
+}
+
+// SplitTypedText splits a printed speech bubble into the lines before the text, the lines of text,
+// and the lines after the text, so that only the text is typed by the "type" animation
+// - The lines before are the top border, if the bubble is drawn
+// - The lines after are the bottom border and the tether
+func SplitTypedText(args Args, bubble string) (string, string, string) {
+	lines := strings.SplitAfter(bubble, "\n")
+	_, stringColumns := TetherRoute(args.HeadColumn, args.Width+4)
+	// the last "line" is the empty string after the final newline
+	start, end := 0, len(lines)-len(stringColumns)-2
+	if args.DrawBubble {
+		start = 1
+	}
+	if end < start {
+		end = start
+	}
+	return strings.Join(lines[:start], ""), strings.Join(lines[start:end], ""), strings.Join(lines[end:], "")
+}
+
+// TypeText writes text to w one character at a time, waiting for the delay after each visible
+// non-space character. Escape sequences are written all at once.
+// If a signal is received on the stop channel, the rest of the text is written immediately
+func TypeText(w io.Writer, text string, delay time.Duration, stop chan os.Signal) {
+	for i := 0; i < len(text); {
+		if n := pokedex.EscapeLength(text[i:]); n > 0 {
+			fmt.Fprint(w, text[i:i+n])
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		fmt.Fprint(w, string(r))
+		i += size
+
+		if r == ' ' || r == '\n' {
+			continue
+		}
+		select {
+		case <-stop:
+			fmt.Fprint(w, text[i:])
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// animateFrames continuously writes frames to w, redrawing each frame over the previous one,
+// until a signal is received on the stop channel
+func animateFrames(w io.Writer, frame func(i int) []string, delay time.Duration, stop chan os.Signal) {
+	height := 0
+	for i := 0; ; i++ {
+		if height > 0 {
+			// move the cursor back up to the start of the previous frame
+			fmt.Fprintf(w, "\033[%dA", height)
+		}
+		lines := frame(i)
+		for _, line := range lines {
+			fmt.Fprintf(w, "\r%s%s%s\n", line, resetColourANSI, clearLineANSI)
+		}
+		height = len(lines)
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// BobFrame returns a frame where the pokemon is either up or down, with an empty line below or
+// above it. The info box is never moved.
+func BobFrame(sprite []string, i int) []string {
+	lines := make([]string, 0, len(sprite)+2)
+	if i%2 == 1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, sprite...)
+	if i%2 == 0 {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// SparkleFrame returns a frame where a few sparkles are drawn in random empty spaces around the
+// pokemon, i.e. in the padding to the left and right of each line of the sprite
+func SparkleFrame(sprite []string) []string {
+	width := 0
+	for _, line := range sprite {
+		if lineWidth := UnicodeStringLength(line); lineWidth > width {
+			width = lineWidth
+		}
+	}
+	width += 2
+
+	// gather all of the empty cells, as {row, column} pairs
+	cells := make([][2]int, 0)
+	for row, line := range sprite {
+		padding := len(line) - len(strings.TrimLeft(line, " "))
+		for col := 0; col < padding; col++ {
+			cells = append(cells, [2]int{row, col})
+		}
+		for col := UnicodeStringLength(line); col < width; col++ {
+			cells = append(cells, [2]int{row, col})
+		}
+	}
+
+	sparkles := make(map[[2]int]string)
+	for i := 0; i < SparkleCount && len(cells) > 0; i++ {
+		sparkles[cells[RandomInt(len(cells))]] = SparkleColours[RandomInt(len(SparkleColours))] +
+			SparkleChars[RandomInt(len(SparkleChars))] +
+			resetColourANSI
+	}
+
+	lines := make([]string, 0, len(sprite)+1)
+	for row, line := range sprite {
+		padding := len(line) - len(strings.TrimLeft(line, " "))
+		lineWidth := UnicodeStringLength(line)
+
+		var b strings.Builder
+		for col := 0; col < padding; col++ {
+			b.WriteString(sparkleOrSpace(sparkles, row, col))
+		}
+		b.WriteString(line[padding:])
+		b.WriteString(resetColourANSI)
+		for col := lineWidth; col < width; col++ {
+			b.WriteString(sparkleOrSpace(sparkles, row, col))
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func sparkleOrSpace(sparkles map[[2]int]string, row int, col int) string {
+	if sparkle, ok := sparkles[[2]int{row, col}]; ok {
+		return sparkle
+	}
+	return " "
+}
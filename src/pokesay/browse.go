@@ -0,0 +1,338 @@
+package pokesay
+
+import (
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"github.com/tmck-code/pokesay/src/pokedex"
+	"golang.org/x/term"
+)
+
+var (
+	BrowseListWidth int = 26 // the width of the name list column in the browser
+
+	enterAltScreenANSI string = "\033[?1049h"
+	exitAltScreenANSI  string = "\033[?1049l"
+	clearScreenANSI    string = "\033[H\033[2J"
+)
+
+// browseItem is a single pokemon that can be selected in the browser
+type browseItem struct {
+	Slug     string
+	Metadata pokedex.PokemonMetadata
+}
+
+// browser holds the state of the interactive pokedex browser
+type browser struct {
+	items      []browseItem
+	categories []string
+	cows       embed.FS
+	cowRootDir string
+
+	query           string
+	toggled         map[string]bool
+	focusCategories bool // whether the arrow keys move through the category toggles, or the name list
+	categoryCursor  int
+	selected        int
+	form            int
+	status          string
+	copied          string
+}
+
+// Browse opens a full-screen interactive browser for all of the pokemon in the pokedex
+// - Typing filters the list of names, and the up/down arrow keys select a name
+// - The left/right arrow keys cycle through every form of the selected pokemon
+// - Tab switches to the category toggles, where left/right choose a category and space toggles it
+// - Enter copies the command to print the selected pokemon to the clipboard (using OSC 52)
+// - Esc or Ctrl-C quits the browser, and prints the last copied command
+//
+// An error is returned if STDIN & STDOUT aren't a terminal.
+func Browse(names map[string][]int, categories []string, metadataFiles embed.FS, metadataRootDir string, cows embed.FS, cowRootDir string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("the browser can only be run in a terminal")
+	}
+
+	b := &browser{
+		categories: categories,
+		cows:       cows,
+		cowRootDir: cowRootDir,
+		toggled:    make(map[string]bool),
+	}
+	for _, slug := range pokedex.GatherMapKeys(names) {
+		for _, idx := range names[slug] {
+			b.items = append(b.items, browseItem{
+				Slug:     slug,
+				Metadata: pokedex.ReadMetadataFromEmbedded(metadataFiles, pokedex.MetadataFpath(metadataRootDir, idx)),
+			})
+		}
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	fmt.Print(enterAltScreenANSI, hideCursorANSI)
+	defer func() {
+		fmt.Print(resetColourANSI, showCursorANSI, exitAltScreenANSI)
+		term.Restore(fd, oldState)
+		if b.copied != "" {
+			fmt.Println(b.copied)
+		}
+	}()
+
+	buf := make([]byte, 16)
+	for {
+		b.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil || !b.handleKey(string(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+// handleKey updates the browser state for a key press, and returns false if the browser should quit
+func (b *browser) handleKey(key string) bool {
+	b.status = ""
+	switch key {
+	case "\x03", "\x1b": // Ctrl-C, Esc
+		return false
+	case "\t":
+		b.focusCategories = !b.focusCategories
+	case "\r", "\n":
+		if item, entry, ok := b.current(); ok {
+			b.copied = ReproduceCommand(item.Slug, entry, item.Metadata.Entries)
+			// OSC 52 asks the terminal to copy the text to the system clipboard
+			fmt.Printf("\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(b.copied)))
+			b.status = "copied: " + b.copied
+		}
+	case "\x1b[A": // up
+		if !b.focusCategories && b.selected > 0 {
+			b.selected, b.form = b.selected-1, 0
+		}
+	case "\x1b[B": // down
+		if !b.focusCategories && b.selected < len(b.visible())-1 {
+			b.selected, b.form = b.selected+1, 0
+		}
+	case "\x1b[C": // right
+		if b.focusCategories && len(b.categories) > 0 {
+			b.categoryCursor = (b.categoryCursor + 1) % len(b.categories)
+		} else if !b.focusCategories {
+			b.form++
+		}
+	case "\x1b[D": // left
+		if b.focusCategories && len(b.categories) > 0 {
+			b.categoryCursor = (b.categoryCursor + len(b.categories) - 1) % len(b.categories)
+		} else if !b.focusCategories && b.form > 0 {
+			b.form--
+		}
+	case " ":
+		if b.focusCategories && len(b.categories) > 0 {
+			category := b.categories[b.categoryCursor]
+			b.toggled[category] = !b.toggled[category]
+			b.selected, b.form = 0, 0
+		} else if !b.focusCategories {
+			b.query += key
+		}
+	case "\x7f", "\b": // backspace
+		if !b.focusCategories && len(b.query) > 0 {
+			_, size := utf8.DecodeLastRuneInString(b.query)
+			b.query = b.query[:len(b.query)-size]
+			b.selected, b.form = 0, 0
+		}
+	default:
+		if !b.focusCategories && !strings.HasPrefix(key, "\x1b") && key >= " " {
+			b.query += strings.ToLower(key)
+			b.selected, b.form = 0, 0
+		}
+	}
+	return true
+}
+
+// forms returns all of the entries of a pokemon that are in every toggled category
+func (b *browser) forms(item browseItem) []pokedex.PokemonEntryMapping {
+	forms := make([]pokedex.PokemonEntryMapping, 0)
+	for _, entry := range item.Metadata.Entries {
+		matches := true
+		for category, on := range b.toggled {
+			if on && !pokedex.ContainsString(entry.Categories, category) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			forms = append(forms, entry)
+		}
+	}
+	return forms
+}
+
+// visible returns the pokemon that match the search query, and have at least one form in every toggled category
+func (b *browser) visible() []browseItem {
+	visible := make([]browseItem, 0)
+	for _, item := range b.items {
+		if strings.Contains(item.Slug, b.query) && len(b.forms(item)) > 0 {
+			visible = append(visible, item)
+		}
+	}
+	return visible
+}
+
+// current returns the selected pokemon, and the selected form of that pokemon
+func (b *browser) current() (browseItem, pokedex.PokemonEntryMapping, bool) {
+	visible := b.visible()
+	if len(visible) == 0 {
+		return browseItem{}, pokedex.PokemonEntryMapping{}, false
+	}
+	if b.selected >= len(visible) {
+		b.selected = len(visible) - 1
+	}
+	item := visible[b.selected]
+	forms := b.forms(item)
+	if b.form >= len(forms) {
+		b.form = len(forms) - 1
+	}
+	return item, forms[b.form], true
+}
+
+// draw redraws the whole screen
+// - the top line shows the category toggles
+// - the left column shows the search query and the matching names
+// - the right column shows a preview of the selected form, and its information
+// - the bottom line shows the key bindings, or a status message
+func (b *browser) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = DefaultTerminalWidth, 24
+	}
+	bodyHeight := height - 3
+
+	// show as many category toggles as fit on the line, making sure that the cursor is on screen
+	toggles, togglesWidth := make([]string, 0, len(b.categories)), 0
+	start := 0
+	for i := 0; i <= b.categoryCursor && i < len(b.categories); i++ {
+		togglesWidth += len(b.categories[i]) + 6
+		if togglesWidth > width {
+			start, togglesWidth = i, len(b.categories[i])+6
+		}
+	}
+	togglesWidth = 0
+	for i := start; i < len(b.categories); i++ {
+		category := b.categories[i]
+		if togglesWidth += len(category) + 6; togglesWidth > width {
+			break
+		}
+		toggle := "[ ] " + category
+		if b.toggled[category] {
+			toggle = "[x] " + category
+		}
+		if b.focusCategories && i == b.categoryCursor {
+			toggle = textStyleReverse.Sprint(toggle)
+		}
+		toggles = append(toggles, toggle)
+	}
+
+	visible := b.visible()
+	left := []string{textStyleBold.Sprint("search: ") + b.query + "_", ""}
+	// scroll the list so that the selected name is always on screen
+	offset := 0
+	if b.selected >= bodyHeight-2 {
+		offset = b.selected - (bodyHeight - 3)
+	}
+	for i := offset; i < len(visible) && len(left) < bodyHeight; i++ {
+		name := visible[i].Slug
+		if len(name) > BrowseListWidth-2 {
+			name = name[:BrowseListWidth-2]
+		}
+		if i == b.selected && !b.focusCategories {
+			name = textStyleReverse.Sprint(name)
+		}
+		left = append(left, name)
+	}
+
+	right := make([]string, 0)
+	if item, entry, ok := b.current(); ok {
+		forms := b.forms(item)
+		right = append(right,
+			fmt.Sprintf("%s | %s (%s)", textStyleBold.Sprint(item.Metadata.Name), item.Metadata.JapaneseName, item.Metadata.JapanesePhonetic),
+			fmt.Sprintf("form %d/%d | %s", b.form+1, len(forms), textStyleItalic.Sprint(strings.Join(entry.Categories, "/"))),
+			"",
+		)
+		sprite := pokedex.ReadPokemonCow(b.cows, pokedex.EntryFpath(b.cowRootDir, entry.EntryIndex))
+		right = append(right, strings.Split(strings.TrimRight(string(sprite), "\n"), "\n")...)
+	} else {
+		right = append(right, "no pokemon match the search")
+	}
+
+	help := "type to search | ↑/↓ select | ←/→ form | tab categories | enter copy command | esc quit"
+	if b.status != "" {
+		help = b.status
+	}
+
+	var screen strings.Builder
+	screen.WriteString(clearScreenANSI)
+	screen.WriteString(strings.Join(toggles, "  ") + resetColourANSI + "\r\n\r\n")
+	for row := 0; row < bodyHeight; row++ {
+		line := ""
+		if row < len(left) {
+			line = left[row]
+		}
+		if padding := BrowseListWidth - UnicodeStringLength(line); padding > 0 {
+			line += resetColourANSI + strings.Repeat(" ", padding)
+		}
+		if row < len(right) {
+			// a wide sprite is cut off, rather than wrapping onto the next line
+			line += ClipLine(right[row], width-BrowseListWidth) + resetColourANSI
+		}
+		screen.WriteString(line + "\r\n")
+	}
+	screen.WriteString(textStyleItalic.Sprint(help))
+	fmt.Print(screen.String())
+}
+
+// ClipLine cuts a line off after width terminal columns, without splitting a grapheme cluster. Escape
+// sequences aren't counted, and the ones after the cut are dropped
+func ClipLine(line string, width int) string {
+	columns := 0
+	for i := 0; i < len(line); {
+		if n := pokedex.EscapeLength(line[i:]); n > 0 {
+			i += n
+			continue
+		}
+		cluster, _, clusterWidth, _ := uniseg.FirstGraphemeClusterInString(line[i:], -1)
+		if columns+clusterWidth > width {
+			return line[:i]
+		}
+		columns, i = columns+clusterWidth, i+len(cluster)
+	}
+	return line
+}
+
+// ReproduceCommand returns the pokesay command that prints the given pokemon form.
+// The category used is the one that narrows down the forms of the pokemon the most, e.g. "shiny"
+// rather than "small" if all forms of the pokemon are small
+func ReproduceCommand(slug string, entry pokedex.PokemonEntryMapping, entries []pokedex.PokemonEntryMapping) string {
+	if len(entry.Categories) == 0 {
+		return fmt.Sprintf("pokesay -n %s", slug)
+	}
+	counts := make(map[string]int)
+	for _, e := range entries {
+		for _, category := range e.Categories {
+			counts[category]++
+		}
+	}
+	// prefer the most specific (i.e. last) category when there's a tie
+	choice := entry.Categories[0]
+	for _, category := range entry.Categories {
+		if counts[category] <= counts[choice] {
+			choice = category
+		}
+	}
+	return fmt.Sprintf("pokesay -n %s -c %s", slug, choice)
+}
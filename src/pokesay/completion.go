@@ -0,0 +1,147 @@
+package pokesay
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	CompletionShells []string = []string{"bash", "zsh", "fish"}
+)
+
+// CompletionOption describes a single command line option, for generating shell completions
+// - Short & Long are the option names, without the leading dashes. Either can be empty
+// - IsFlag is true if the option doesn't take a value
+// - Values are the possible values for the option (if any), to complete after the option
+type CompletionOption struct {
+	Short  string
+	Long   string
+	IsFlag bool
+	Values []string
+}
+
+// GenerateCompletion returns a shell completion script for pokesay, for one of the CompletionShells
+// - The options are completed after a "-" or "--"
+// - The values of an option are completed after the option, e.g. pokemon names after "--name"
+// - The commands are completed as the first positional argument, and the shells after "completion"
+func GenerateCompletion(shell string, options []CompletionOption, commands []string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(options, commands), nil
+	case "zsh":
+		return zshCompletion(options, commands), nil
+	case "fish":
+		return fishCompletion(options, commands), nil
+	}
+	return "", fmt.Errorf("unsupported shell '%s', must be one of: %s", shell, strings.Join(CompletionShells, ", "))
+}
+
+func bashCompletion(options []CompletionOption, commands []string) string {
+	var b strings.Builder
+	allOptions := make([]string, 0)
+
+	b.WriteString("# bash completion for pokesay\n")
+	b.WriteString("_pokesay() {\n")
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	b.WriteString("    case \"$prev\" in\n")
+	for _, option := range options {
+		names := make([]string, 0, 2)
+		if option.Short != "" {
+			names = append(names, "-"+option.Short)
+		}
+		if option.Long != "" {
+			names = append(names, "--"+option.Long)
+		}
+		allOptions = append(allOptions, names...)
+		if option.IsFlag {
+			continue
+		}
+		fmt.Fprintf(&b, "        %s)\n", strings.Join(names, "|"))
+		fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(option.Values, " "))
+		b.WriteString("            return ;;\n")
+	}
+	b.WriteString("        completion)\n")
+	fmt.Fprintf(&b, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(CompletionShells, " "))
+	b.WriteString("            return ;;\n")
+	b.WriteString("    esac\n")
+	b.WriteString("    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(allOptions, " "))
+	b.WriteString("    else\n")
+	fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(commands, " "))
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	b.WriteString("complete -F _pokesay pokesay\n")
+
+	return b.String()
+}
+
+func zshCompletion(options []CompletionOption, commands []string) string {
+	var b strings.Builder
+
+	b.WriteString("#compdef pokesay\n")
+	b.WriteString("# zsh completion for pokesay\n")
+	b.WriteString("_pokesay() {\n")
+	b.WriteString("    _arguments -s \\\n")
+	for _, option := range options {
+		names := make([]string, 0, 2)
+		if option.Short != "" {
+			names = append(names, "-"+option.Short)
+		}
+		if option.Long != "" {
+			names = append(names, "--"+option.Long)
+		}
+		action := ""
+		if !option.IsFlag && len(option.Values) > 0 {
+			action = fmt.Sprintf(":%s:(%s)", option.Long, strings.Join(option.Values, " "))
+		} else if !option.IsFlag {
+			action = fmt.Sprintf(":%s: ", option.Long)
+		}
+		for i, name := range names {
+			if !option.IsFlag && strings.HasPrefix(name, "--") {
+				names[i] = name + "="
+			} else if !option.IsFlag {
+				names[i] = name + "+"
+			}
+		}
+		if action != "" {
+			action = "'" + action + "'"
+		}
+		if len(names) == 1 {
+			fmt.Fprintf(&b, "        '%s'%s \\\n", names[0], action)
+		} else {
+			fmt.Fprintf(&b, "        '(-%s --%s)'{%s}%s \\\n", option.Short, option.Long, strings.Join(names, ","), action)
+		}
+	}
+	fmt.Fprintf(&b, "        '1:command:(%s)' \\\n", strings.Join(commands, " "))
+	fmt.Fprintf(&b, "        '2:shell:(%s)'\n", strings.Join(CompletionShells, " "))
+	b.WriteString("}\n")
+	b.WriteString("compdef _pokesay pokesay\n")
+
+	return b.String()
+}
+
+func fishCompletion(options []CompletionOption, commands []string) string {
+	var b strings.Builder
+
+	b.WriteString("# fish completion for pokesay\n")
+	b.WriteString("complete -c pokesay -f\n")
+	fmt.Fprintf(&b, "complete -c pokesay -n __fish_use_subcommand -a '%s'\n", strings.Join(commands, " "))
+	fmt.Fprintf(&b, "complete -c pokesay -n '__fish_seen_subcommand_from completion' -a '%s'\n", strings.Join(CompletionShells, " "))
+	for _, option := range options {
+		line := "complete -c pokesay"
+		if option.Short != "" {
+			line += " -s " + option.Short
+		}
+		if option.Long != "" {
+			line += " -l " + option.Long
+		}
+		if !option.IsFlag {
+			line += " -x"
+			if len(option.Values) > 0 {
+				line += fmt.Sprintf(" -a '%s'", strings.Join(option.Values, " "))
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
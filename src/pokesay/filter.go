@@ -0,0 +1,358 @@
+package pokesay
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+var (
+	// The colour filters that are used by name alone, without a value (e.g. for shell completion)
+	NamedColourFilters []string = []string{"grayscale", "sepia", "invert", "silhouette"}
+	// The colour filters that can be applied to a pokemon, the named filters and the filters with a value
+	// - hue rotates the colours by a number of degrees, e.g. hue:+120
+	// - map replaces one colour with another, as a 256 colour index or #rrggbb, e.g. map:16=238. Colours
+	// are matched by their closest 256 colour, so a filter before it (or a #rrggbb colour that isn't
+	// one of the 256 colours) still matches
+	ColourFilters []string = append(append([]string{}, NamedColourFilters...), "hue:<degrees>", "map:<from>=<to>")
+	// The colour of every cell of a pokemon with the silhouette filter
+	SilhouetteColour RGB = RGB{0x44, 0x44, 0x44}
+	// The colour of the (black) outlines of a pokemon on a light background
+	LightOutlineColour RGB = RGB{0x4e, 0x4e, 0x4e}
+	// The colour of the info box text on a light background, instead of the terminal's text colour
+	LightInfoTextColour RGB = RGB{0x26, 0x26, 0x26}
+)
+
+// RGB is a colour, with red, green & blue values from 0 to 255
+type RGB [3]float64
+
+// ColourFilter transforms a colour of a pokemon sprite
+type ColourFilter func(c RGB) RGB
+
+// ParseColourFilter returns the colour filter with a name, see ColourFilters
+func ParseColourFilter(name string) (ColourFilter, error) {
+	switch name {
+	case "grayscale":
+		return func(c RGB) RGB {
+			luma := 0.299*c[0] + 0.587*c[1] + 0.114*c[2]
+			return RGB{luma, luma, luma}
+		}, nil
+	case "sepia":
+		return func(c RGB) RGB {
+			return RGB{
+				0.393*c[0] + 0.769*c[1] + 0.189*c[2],
+				0.349*c[0] + 0.686*c[1] + 0.168*c[2],
+				0.272*c[0] + 0.534*c[1] + 0.131*c[2],
+			}
+		}, nil
+	case "invert":
+		return func(c RGB) RGB { return RGB{255 - c[0], 255 - c[1], 255 - c[2]} }, nil
+	case "silhouette":
+		return func(c RGB) RGB { return SilhouetteColour }, nil
+	}
+	if degrees, ok := strings.CutPrefix(name, "hue:"); ok {
+		if d, err := strconv.ParseFloat(degrees, 64); err == nil && !math.IsNaN(d) && !math.IsInf(d, 0) {
+			return func(c RGB) RGB { return rotateHue(c, d) }, nil
+		}
+	}
+	if mapping, ok := strings.CutPrefix(name, "map:"); ok {
+		from, to, _ := strings.Cut(mapping, "=")
+		fromColour, fromErr := parseColour(from)
+		toColour, toErr := parseColour(to)
+		if fromErr == nil && toErr == nil {
+			from := xtermIndex(fromColour)
+			return func(c RGB) RGB {
+				if xtermIndex(c) == from {
+					return toColour
+				}
+				return c
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid filter '%s', must be one of: %s", name, strings.Join(ColourFilters, ", "))
+}
+
+// parseColour parses a 256 colour index (e.g. 238), or a 24-bit colour (e.g. #444444)
+func parseColour(s string) (RGB, error) {
+	if hex, ok := strings.CutPrefix(s, "#"); ok && len(hex) == 6 {
+		var c RGB
+		for i := range c {
+			v, err := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+			if err != nil {
+				return RGB{}, err
+			}
+			c[i] = float64(v)
+		}
+		return c, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > 255 {
+		return RGB{}, fmt.Errorf("invalid colour '%s'", s)
+	}
+	return xtermRGB(n), nil
+}
+
+// LightBackground is a colour filter for terminals with a light background, as the sprites are
+// drawn for a dark background
+// - The black outlines are softened to a dark gray (the LightOutlineColour)
+// - Colours that are close to white are darkened, so that they don't blend into the background
+func LightBackground(c RGB) RGB {
+	switch luma := 0.299*c[0] + 0.587*c[1] + 0.114*c[2]; {
+	case luma < 0x10:
+		return LightOutlineColour
+	case luma > 0xe0:
+		return RGB{c[0] * 0.85, c[1] * 0.85, c[2] * 0.85}
+	}
+	return c
+}
+
+// ReadableOnLight adjusts the text colours of a line (e.g. rendered markup) for a light background.
+// Only explicit colours are changed, see DarkenDefaultText for the text in the terminal's own colour
+// - White text is printed in black
+// - Bright colours are printed in their normal (darker) colour, e.g. bright yellow -> yellow
+func ReadableOnLight(line string) string {
+	return mapSGR(line, func(params string) string {
+		p := strings.Split(params, ";")
+		for i := 0; i < len(p); i++ {
+			switch p[i] {
+			case "38", "48":
+				// skip the parameters of an extended colour, e.g. "38;5;97"
+				if i+1 < len(p) && p[i+1] == "2" {
+					i += 4
+				} else {
+					i += 2
+				}
+			case "37", "97":
+				p[i] = "30"
+			case "90", "91", "92", "93", "94", "95", "96":
+				p[i] = "3" + p[i][1:]
+			}
+		}
+		return strings.Join(p, ";")
+	})
+}
+
+// DarkenDefaultText prints the text of a line that is in the terminal's own text colour (e.g. the
+// bold & italic names of the default info box) in the LightInfoTextColour instead, for a light
+// background. Many terminals print bold text in a brighter (i.e. paler) colour, which can be hard to
+// read on a light background, but the 256 colours are never brightened
+// - Explicit colours (e.g. from markup) are kept, and the dark colour is re-applied after them
+func DarkenDefaultText(line string) string {
+	if line == "" {
+		return line
+	}
+	foreground := "38;5;" + strconv.Itoa(xtermIndex(LightInfoTextColour))
+	return "\033[" + foreground + "m" + mapSGR(line, func(params string) string {
+		p := strings.Split(params, ";")
+		for i := 0; i < len(p); i++ {
+			switch p[i] {
+			case "38", "48":
+				// skip the parameters of an extended colour, e.g. "38;5;0"
+				if i+1 < len(p) && p[i+1] == "2" {
+					i += 4
+				} else {
+					i += 2
+				}
+			case "", "0", "39":
+				// the foreground colour was reset
+				return params + ";" + foreground
+			}
+		}
+		return params
+	}) + "\033[39m"
+}
+
+// FilterSprite applies colour filters to a sprite, in order
+// - The 256 colour (e.g. "\033[38;5;160m") & 24-bit colour (e.g. "\033[48;2;255;0;0m") escape
+// sequences are transformed, and re-emitted in the same form
+// - Any other escape sequences (e.g. colour resets) are kept as-is
+func FilterSprite(sprite []byte, filters []ColourFilter) []byte {
+	if len(filters) == 0 {
+		return sprite
+	}
+	apply := func(c RGB) RGB {
+		for _, filter := range filters {
+			c = filter(c)
+		}
+		return c
+	}
+	// sprites only use a few colours, so each one is only transformed once
+	indexes := make(map[string]string)
+	return []byte(mapSGR(string(sprite), func(params string) string { return filterSGR(params, apply, indexes) }))
+}
+
+// mapSGR replaces the parameters of each SGR escape sequence in text (e.g. "1;38;5;160" in
+// "\033[1;38;5;160m"). Any other escape sequences, and SGR sequences with colon-separated
+// parameters, are kept as-is
+func mapSGR(text string, f func(params string) string) string {
+	var b strings.Builder
+	for len(text) > 0 {
+		n := pokedex.EscapeLength(text)
+		if n == 0 {
+			next := strings.IndexByte(text, '\033')
+			if next < 0 {
+				next = len(text)
+			} else if next == 0 {
+				next = 1
+			}
+			b.WriteString(text[:next])
+			text = text[next:]
+			continue
+		}
+		seq := text[:n]
+		if strings.HasPrefix(seq, "\033[") && strings.HasSuffix(seq, "m") && !strings.Contains(seq, ":") {
+			seq = "\033[" + f(seq[2:len(seq)-1]) + "m"
+		}
+		b.WriteString(seq)
+		text = text[n:]
+	}
+	return b.String()
+}
+
+// filterSGR transforms the foreground & background colours in the parameters of an SGR sequence
+func filterSGR(params string, apply func(RGB) RGB, indexes map[string]string) string {
+	p := strings.Split(params, ";")
+	for i := 0; i+2 < len(p); i++ {
+		if p[i] != "38" && p[i] != "48" {
+			continue
+		}
+		switch {
+		case p[i+1] == "5":
+			if _, ok := indexes[p[i+2]]; !ok {
+				n, err := strconv.Atoi(p[i+2])
+				if err != nil || n < 0 || n > 255 {
+					indexes[p[i+2]] = p[i+2]
+				} else {
+					indexes[p[i+2]] = strconv.Itoa(xtermIndex(apply(xtermRGB(n))))
+				}
+			}
+			p[i+2] = indexes[p[i+2]]
+			i += 2
+		case p[i+1] == "2" && i+4 < len(p):
+			var c RGB
+			for j := range c {
+				v, _ := strconv.Atoi(p[i+2+j])
+				c[j] = float64(v)
+			}
+			for j, v := range apply(c) {
+				p[i+2+j] = strconv.Itoa(int(math.Round(clampColour(v))))
+			}
+			i += 4
+		}
+	}
+	return strings.Join(p, ";")
+}
+
+// the colours of the 16 standard xterm colours
+var xtermStandardColours [16]RGB = [16]RGB{
+	{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0}, {0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+	{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// the levels of each channel in the 6x6x6 colour cube of the xterm 256 colours (16-231)
+var xtermCubeLevels [6]float64 = [6]float64{0, 95, 135, 175, 215, 255}
+
+// xtermRGB returns the colour of an xterm 256 colour index
+func xtermRGB(n int) RGB {
+	switch {
+	case n < 16:
+		return xtermStandardColours[n]
+	case n < 232:
+		n -= 16
+		return RGB{xtermCubeLevels[n/36], xtermCubeLevels[n/6%6], xtermCubeLevels[n%6]}
+	default:
+		level := float64(8 + 10*(n-232))
+		return RGB{level, level, level}
+	}
+}
+
+// xtermIndex returns the xterm 256 colour index that is closest to a colour, from the colour cube
+// or the grayscale ramp (the 16 standard colours depend on the terminal's theme, so aren't used)
+func xtermIndex(c RGB) int {
+	cube := 16
+	for i, multiplier := range []int{36, 6, 1} {
+		cube += nearestLevel(clampColour(c[i])) * multiplier
+	}
+	gray := 232 + int(math.Round((clampColour((c[0]+c[1]+c[2])/3)-8)/10))
+	if gray < 232 {
+		gray = 232
+	} else if gray > 255 {
+		gray = 255
+	}
+	if colourDistance(c, xtermRGB(gray)) < colourDistance(c, xtermRGB(cube)) {
+		return gray
+	}
+	return cube
+}
+
+// nearestLevel returns the index of the colour cube level that is closest to a channel value
+func nearestLevel(v float64) int {
+	nearest := 0
+	for i, level := range xtermCubeLevels {
+		if math.Abs(v-level) < math.Abs(v-xtermCubeLevels[nearest]) {
+			nearest = i
+		}
+	}
+	return nearest
+}
+
+func colourDistance(a RGB, b RGB) float64 {
+	return (a[0]-b[0])*(a[0]-b[0]) + (a[1]-b[1])*(a[1]-b[1]) + (a[2]-b[2])*(a[2]-b[2])
+}
+
+func clampColour(v float64) float64 {
+	return math.Max(0, math.Min(255, v))
+}
+
+// rotateHue rotates the hue of a colour by a number of degrees, keeping its saturation & lightness
+func rotateHue(c RGB, degrees float64) RGB {
+	r, g, b := c[0]/255, c[1]/255, c[2]/255
+	high, low := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	lightness := (high + low) / 2
+	if high == low {
+		return c // gray has no hue
+	}
+
+	delta := high - low
+	saturation := delta / (1 - math.Abs(2*lightness-1))
+	var hue float64
+	switch high {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue = math.Mod(hue*60+degrees, 360)
+	if hue < 0 {
+		hue += 360
+	}
+
+	// convert back from HSL
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var rgb RGB
+	switch {
+	case hue < 60:
+		rgb = RGB{chroma, x, 0}
+	case hue < 120:
+		rgb = RGB{x, chroma, 0}
+	case hue < 180:
+		rgb = RGB{0, chroma, x}
+	case hue < 240:
+		rgb = RGB{0, x, chroma}
+	case hue < 300:
+		rgb = RGB{x, 0, chroma}
+	default:
+		rgb = RGB{chroma, 0, x}
+	}
+	m := lightness - chroma/2
+	for i := range rgb {
+		rgb[i] = (rgb[i] + m) * 255
+	}
+	return rgb
+}
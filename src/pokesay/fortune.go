@@ -0,0 +1,33 @@
+package pokesay
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ChooseFortune returns a random fortune
+func ChooseFortune(fortunes []string) string {
+	return fortunes[RandomInt(len(fortunes))]
+}
+
+// MessageInput returns the reader that the text for the speech bubble is read from
+// - If fortune is true, or nothing is piped into STDIN (i.e. it's a terminal), or STDIN is empty,
+// then a random fortune is used, so that pokesay never waits for input that won't arrive
+// - Otherwise, the text is read from STDIN
+//
+// The fortunes are only loaded if they are needed.
+func MessageInput(stdin *os.File, fortune bool, fortunes func() []string) io.Reader {
+	if fortune || term.IsTerminal(int(stdin.Fd())) {
+		return strings.NewReader(ChooseFortune(fortunes()))
+	}
+	// peek at the first byte, to check whether STDIN is empty without consuming any of it
+	r := bufio.NewReader(stdin)
+	if _, err := r.Peek(1); err == io.EOF {
+		return strings.NewReader(ChooseFortune(fortunes()))
+	}
+	return r
+}
@@ -0,0 +1,80 @@
+package pokesay
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+var (
+	InfoGridGap int = 2 // the number of spaces between each sprite in an info card grid
+)
+
+// RenderInfoCard returns the lines of a pokedex card for a pokemon
+// - The header shows the english, japanese & romaji names, and the number of entries
+// - Below the header, every sprite is laid out in a grid that fits within the width, with each
+// sprite labelled with its categories
+//
+// The sprites must be in the same order as the metadata entries.
+func RenderInfoCard(metadata pokedex.PokemonMetadata, sprites [][]byte, width int, boxChars *BoxChars) []string {
+	header := []string{
+		textStyleBold.Sprint(metadata.Name),
+		fmt.Sprintf("%s (%s)", metadata.JapaneseName, metadata.JapanesePhonetic),
+		fmt.Sprintf("%d entries", len(metadata.Entries)),
+	}
+	headerWidth := 0
+	for _, line := range header {
+		if lineWidth := UnicodeStringLength(line); lineWidth > headerWidth {
+			headerWidth = lineWidth
+		}
+	}
+
+	card := []string{boxChars.TopLeftCorner + strings.Repeat(boxChars.HorizontalEdge, headerWidth+2) + boxChars.TopRightCorner}
+	for _, line := range header {
+		card = append(card, fmt.Sprintf(
+			"%s %s%s%s %s",
+			boxChars.VerticalEdge, line, resetColourANSI, strings.Repeat(" ", headerWidth-UnicodeStringLength(line)), boxChars.VerticalEdge,
+		))
+	}
+	card = append(card, boxChars.BottomLeftCorner+strings.Repeat(boxChars.HorizontalEdge, headerWidth+2)+boxChars.BottomRightCorner)
+
+	blocks := make([][]string, 0, len(sprites))
+	for i, sprite := range sprites {
+		block := strings.Split(strings.TrimRight(string(sprite), "\n"), "\n")
+		label := strings.Join(metadata.Entries[i].Categories, boxChars.CategorySeparator)
+		blocks = append(blocks, append(block, textStyleItalic.Sprint(label)))
+	}
+	for _, row := range LayoutGrid(blocks, width, InfoGridGap) {
+		card = append(card, "")
+		card = append(card, row...)
+	}
+	return card
+}
+
+// LayoutGrid joins blocks of lines into rows, with as many blocks in each row as fit within the
+// width. A block that is wider than the width is put in a row on its own.
+func LayoutGrid(blocks [][]string, width int, gap int) [][]string {
+	rows := make([][]string, 0)
+	row, rowWidth := make([][]string, 0), 0
+	for _, block := range blocks {
+		blockWidth := 0
+		for _, line := range block {
+			if lineWidth := UnicodeStringLength(line); lineWidth > blockWidth {
+				blockWidth = lineWidth
+			}
+		}
+		if len(row) > 0 && rowWidth+gap+blockWidth > width {
+			rows = append(rows, JoinBlocks(row, gap))
+			row, rowWidth = make([][]string, 0), 0
+		}
+		if len(row) > 0 {
+			rowWidth += gap
+		}
+		row, rowWidth = append(row, block), rowWidth+blockWidth
+	}
+	if len(row) > 0 {
+		rows = append(rows, JoinBlocks(row, gap))
+	}
+	return rows
+}
@@ -0,0 +1,153 @@
+package pokesay
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+var (
+	// Where the info box is printed, relative to the pokemon. With "above", the info box is between
+	// the speech bubble and the pokemon, so the bubble's tether points at the info box
+	InfoPositions []string = []string{"below", "above", "beside"}
+	// The placeholders that can be used in an info format, e.g. "{name} #{dex}". The name in each
+	// language (e.g. {zh}, see pokedex.Languages) and the category in each dimension (e.g. {gen}) can
+	// also be used
+	InfoPlaceholders []string = []string{"name", "dex", "jp", "romaji", "categories", "arrow", "sep"}
+)
+
+// PokemonInfo is the information about a chosen pokemon that can be printed in its info box
+type PokemonInfo struct {
+	Name             string
+	Dex              string // the national pokedex number, e.g. "025"
+	Japanese         string
+	JapanesePhonetic string
+	Names            map[string]string // the name in each language, see pokedex.Languages
+	Categories       []string          // the categories to print, see EntryCategories
+	Dimensions       map[string]string // the category in each dimension, e.g. {"gen": "gen8"}
+}
+
+// NewPokemonInfo returns the info of a chosen pokemon entry, with the categories of the given
+// dimensions (or all of the categories, if no dimensions are given)
+func NewPokemonInfo(metadata pokedex.PokemonMetadata, entry pokedex.PokemonEntryMapping, dimensions []string) PokemonInfo {
+	return PokemonInfo{
+		Name:             metadata.Name,
+		Dex:              metadata.Dex,
+		Japanese:         metadata.JapaneseName,
+		JapanesePhonetic: metadata.JapanesePhonetic,
+		Names:            metadata.Names,
+		Categories:       EntryCategories(entry, dimensions),
+		Dimensions:       entry.Dimensions,
+	}
+}
+
+// DefaultInfoFormat returns the info format that is used when no format is given
+// - The name is printed in each of the languages, in order, or in english if no languages are given
+// - The japanese name & romaji are printed after the names if japaneseName is true
+// - The categories are printed at the end if categoryInfo is true
+func DefaultInfoFormat(languages []string, japaneseName bool, categoryInfo bool) string {
+	names := make([]string, 0, len(languages))
+	for _, language := range languages {
+		names = append(names, "**{"+language+"}**")
+	}
+	if len(names) == 0 {
+		names = append(names, "**{name}**")
+	}
+	format := "{arrow} " + strings.Join(names, " {sep} ")
+	if japaneseName {
+		format += " {sep} **{jp} ({romaji})**"
+	}
+	if categoryInfo {
+		format += " {sep} *{categories}*"
+	}
+	return format
+}
+
+// infoValues returns the value of each info format placeholder for a pokemon
+func infoValues(args Args, info PokemonInfo) map[string]string {
+	values := map[string]string{
+		"name":       info.Name,
+		"dex":        info.Dex,
+		"jp":         info.Japanese,
+		"romaji":     info.JapanesePhonetic,
+		"categories": strings.Join(info.Categories, args.BoxChars.CategorySeparator),
+		"arrow":      args.BoxChars.RightArrow,
+		"sep":        args.BoxChars.Separator,
+	}
+	// names that aren't known in a language are printed in english
+	for _, language := range pokedex.Languages {
+		values[language] = info.Name
+		if name := info.Names[language]; name != "" {
+			values[language] = name
+		}
+	}
+	for _, dimension := range append([]string{pokedex.SizeDimension}, pokedex.DirectoryDimensions...) {
+		values[dimension] = info.Dimensions[dimension]
+	}
+	return values
+}
+
+// ValidateInfoFormat returns an error if an info format has a placeholder that doesn't exist
+func ValidateInfoFormat(format string) error {
+	_, err := ExpandInfoFormat(format, infoValues(Args{BoxChars: AsciiBoxChars}, PokemonInfo{}))
+	return err
+}
+
+// ExpandInfoFormat replaces each {placeholder} in an info format with its value
+// - The values are escaped, so that they're never rendered as markup (see RenderMarkup)
+// - A "{" without a matching "}" is kept as-is
+// - A placeholder that has no value is an error
+func ExpandInfoFormat(format string, values map[string]string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(format, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(format[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		name := format[start+1 : end]
+		value, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf(
+				"unknown placeholder '{%s}' in info format, must be one of: %s", name, strings.Join(placeholderNames(), ", "),
+			)
+		}
+		b.WriteString(format[:start])
+		b.WriteString(EscapeMarkup(value))
+		format = format[end+1:]
+	}
+	b.WriteString(format)
+	return b.String(), nil
+}
+
+// placeholderNames returns the names of the placeholders, in the order that they're documented
+func placeholderNames() []string {
+	names := append(append(make([]string, 0), InfoPlaceholders...), pokedex.Languages...)
+	names = append(names, pokedex.SizeDimension)
+	return append(names, pokedex.DirectoryDimensions...)
+}
+
+// LayoutPokemon joins the lines of a pokemon sprite & its info box, with the info box in the
+// InfoPosition (below by default)
+// - "above" & "below" stack the info box & sprite (see InfoPositions)
+// - "beside" puts the info box to the right of the sprite, and vertically centred
+func LayoutPokemon(args Args, sprite []string, info string) []string {
+	infoLines := strings.Split(strings.TrimRight(info, "\n"), "\n")
+	switch args.InfoPosition {
+	case "above":
+		return append(infoLines, sprite...)
+	case "beside":
+		// the blocks are aligned to the bottom, so the info box is raised by the lines below it
+		for below := (len(sprite) - len(infoLines)) / 2; below > 0; below-- {
+			infoLines = append(infoLines, "")
+		}
+		return JoinBlocks([][]string{sprite, infoLines}, SceneGap)
+	default:
+		return append(append(make([]string, 0, len(sprite)+len(infoLines)), sprite...), infoLines...)
+	}
+}
@@ -0,0 +1,136 @@
+package pokesay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+// LineReader reads lines of text to print in a speech bubble, like a bufio.Scanner, but without a
+// limit on the length of a line
+// - Windows (CRLF) & old mac (CR) line endings are treated as line breaks, so that no stray carriage
+// returns are printed
+// - Each line is cleaned with SanitiseLine
+type LineReader struct {
+	reader    *bufio.Reader
+	stripANSI bool
+	pending   []string // the lines that have been read, but not returned yet
+	line      string
+	err       error
+}
+
+// NewLineReader returns a LineReader that reads from r. If stripANSI is true, then all escape
+// sequences are removed from the text
+func NewLineReader(r io.Reader, stripANSI bool) *LineReader {
+	return &LineReader{reader: bufio.NewReader(r), stripANSI: stripANSI}
+}
+
+// Scan reads the next line, which is then available from Text. It returns false when there are no
+// more lines, or an error occurred (see Err)
+func (l *LineReader) Scan() bool {
+	for len(l.pending) == 0 {
+		if l.err != nil {
+			return false
+		}
+		chunk, err := l.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			l.err = err
+			return false
+		}
+		if err == io.EOF {
+			l.err = io.EOF
+			if chunk == "" {
+				return false
+			}
+		}
+		chunk = strings.TrimSuffix(strings.TrimSuffix(chunk, "\n"), "\r")
+		l.pending = strings.Split(chunk, "\r")
+	}
+	l.line, l.pending = SanitiseLine(l.pending[0], l.stripANSI), l.pending[1:]
+	return true
+}
+
+// Text returns the line that was read by the last call to Scan
+func (l *LineReader) Text() string {
+	return l.line
+}
+
+// Err returns the first error that occurred while reading, other than io.EOF
+func (l *LineReader) Err() error {
+	if l.err == io.EOF {
+		return nil
+	}
+	return l.err
+}
+
+// SanitiseLine makes a line of text safe to print in a speech bubble
+// - Invalid UTF-8 is replaced with the unicode replacement character (U+FFFD)
+// - Escape sequences that style the text (SGR colours) or are hyperlinks (OSC 8) are kept, unless
+// stripANSI is true. Any other escape sequences (e.g. cursor movement or clearing the screen) are
+// removed, as they would break the speech bubble
+// - Control characters other than tabs are escaped with caret notation, e.g. "\x00" -> "^@", and
+// "\x7f" -> "^?". C1 control characters are replaced with U+FFFD
+func SanitiseLine(line string, stripANSI bool) string {
+	// most lines are plain, printable ASCII, so they can be returned as-is
+	clean := true
+	for i := 0; i < len(line) && clean; i++ {
+		clean = (line[i] >= 0x20 && line[i] < 0x7f) || line[i] == '\t'
+	}
+	if clean {
+		return line
+	}
+
+	line = strings.ToValidUTF8(line, string(utf8.RuneError))
+	var b strings.Builder
+	for len(line) > 0 {
+		if n := pokedex.EscapeLength(line); n > 1 && escapeTerminated(line[:n]) {
+			if !stripANSI && keepEscape(line[:n]) {
+				b.WriteString(line[:n])
+			}
+			line = line[n:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line)
+		switch {
+		case r == '\t':
+			b.WriteRune(r)
+		case r < 0x20:
+			fmt.Fprintf(&b, "^%c", r+0x40)
+		case r == 0x7f:
+			b.WriteString("^?")
+		case r >= 0x80 && r < 0xa0:
+			b.WriteRune(utf8.RuneError)
+		default:
+			b.WriteString(line[:size])
+		}
+		line = line[size:]
+	}
+	return b.String()
+}
+
+// escapeTerminated returns whether an escape sequence (see pokedex.EscapeLength) is complete, rather
+// than cut off at the end of a line
+func escapeTerminated(seq string) bool {
+	last := seq[len(seq)-1]
+	switch {
+	case seq[1] == '[':
+		return len(seq) > 2 && last >= 0x40 && last <= 0x7e
+	case strings.ContainsRune("]PX^_", rune(seq[1])):
+		return last == '\a' || strings.HasSuffix(seq, "\033\\")
+	default:
+		return last >= 0x30 && last <= 0x7e
+	}
+}
+
+// keepEscape returns whether an escape sequence can be printed in a speech bubble, i.e. it's an
+// SGR sequence (e.g. "\033[31m") or an OSC 8 hyperlink (e.g. "\033]8;;https://example.com\033\\")
+func keepEscape(seq string) bool {
+	if strings.HasPrefix(seq, "\033[") {
+		return strings.HasSuffix(seq, "m") && strings.Trim(seq[2:len(seq)-1], "0123456789;:") == ""
+	}
+	return strings.HasPrefix(seq, "\033]8;")
+}
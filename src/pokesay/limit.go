@@ -0,0 +1,66 @@
+package pokesay
+
+// LineLimit keeps at most a maximum number of the lines of text in a speech bubble, and counts
+// the lines that are dropped
+// - If head is true (or neither head or tail is), then the first lines are kept
+// - If tail is true, then the last lines are kept
+// - If both are true, then the first & last halves are kept, and the lines in the middle are dropped
+//
+// Lines are added one at a time, so that only the kept lines are stored, even for huge inputs.
+type LineLimit struct {
+	first   []string
+	last    []string // a ring buffer of the last lines
+	next    int      // the index in last that the next line is written to
+	nFirst  int
+	nLast   int
+	dropped int
+}
+
+// NewLineLimit returns a LineLimit that keeps at most max lines
+func NewLineLimit(max int, head bool, tail bool) *LineLimit {
+	l := &LineLimit{first: make([]string, 0), last: make([]string, 0)}
+	switch {
+	case head && tail:
+		l.nFirst, l.nLast = (max+1)/2, max/2
+	case tail:
+		l.nLast = max
+	default:
+		l.nFirst = max
+	}
+	return l
+}
+
+// Add adds a line, which is either kept or dropped
+func (l *LineLimit) Add(line string) {
+	if len(l.first) < l.nFirst {
+		l.first = append(l.first, line)
+		return
+	}
+	if l.nLast == 0 {
+		l.dropped++
+		return
+	}
+	if len(l.last) < l.nLast {
+		l.last = append(l.last, line)
+		return
+	}
+	// the oldest of the last lines is dropped
+	l.last[l.next] = line
+	l.next = (l.next + 1) % l.nLast
+	l.dropped++
+}
+
+// Dropped returns the number of lines that were dropped
+func (l *LineLimit) Dropped() int {
+	return l.dropped
+}
+
+// Lines returns the kept lines, in order, with an ellipsis line where any lines were dropped
+func (l *LineLimit) Lines(ellipsis string) []string {
+	lines := append(make([]string, 0, len(l.first)+len(l.last)+1), l.first...)
+	if l.dropped > 0 {
+		lines = append(lines, ellipsis)
+	}
+	lines = append(lines, l.last[l.next:]...)
+	return append(lines, l.last[:l.next]...)
+}
@@ -0,0 +1,188 @@
+package pokesay
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+var (
+	// The formats that names & categories can be listed in
+	ListFormats []string = []string{"columns", "lines", "json", "csv"}
+)
+
+// CategoryInfo is a category, along with the number of sprites & species (i.e. names) in it
+type CategoryInfo struct {
+	Name      string `json:"name"`
+	Dimension string `json:"dimension"`
+	Sprites   int    `json:"sprites"`
+	Species   int    `json:"species"`
+}
+
+// CountCategories counts the number of sprites & species in each category, using the metadata of
+// every pokemon
+// - The categories are returned grouped by dimension, in the order of the dimension index
+// - Any categories that aren't in a dimension are returned last, in the "other" dimension
+func CountCategories(categories []string, index []pokedex.CategoryDimension, metadata []pokedex.PokemonMetadata) []CategoryInfo {
+	sprites, species := make(map[string]int), make(map[string]int)
+	for _, m := range metadata {
+		seen := make(map[string]bool)
+		for _, entry := range m.Entries {
+			for _, category := range entry.Categories {
+				sprites[category]++
+				seen[category] = true
+			}
+		}
+		for category := range seen {
+			species[category]++
+		}
+	}
+
+	infos := make([]CategoryInfo, 0, len(categories))
+	for _, dimension := range index {
+		for _, category := range dimension.Values {
+			infos = append(infos, CategoryInfo{category, dimension.Name, sprites[category], species[category]})
+		}
+	}
+	for _, category := range categories {
+		if pokedex.FindDimension(index, category) == "" {
+			infos = append(infos, CategoryInfo{category, "other", sprites[category], species[category]})
+		}
+	}
+	return infos
+}
+
+// NamesInCategory returns the names that have at least one sprite in a category
+// - The category can be qualified with its dimension, e.g. variant=shiny (see ParseCategory)
+// - An error is returned if the category isn't one of the category keys, or isn't in the dimension
+func NamesInCategory(names map[string][]int, category string, categoryKeys []string, index []pokedex.CategoryDimension, metadataFor func(idx int) pokedex.PokemonMetadata) ([]string, error) {
+	category, err := ParseCategory(category, index)
+	if err != nil {
+		return nil, err
+	}
+	if !pokedex.ContainsString(categoryKeys, category) {
+		return nil, fmt.Errorf("%w by category '%s'", ErrNotFound, category)
+	}
+	matches := make([]string, 0)
+	for _, name := range ListNames(names) {
+	search:
+		for _, idx := range names[name] {
+			for _, entry := range metadataFor(idx).Entries {
+				if pokedex.ContainsString(entry.Categories, category) {
+					matches = append(matches, name)
+					break search
+				}
+			}
+		}
+	}
+	return matches, nil
+}
+
+// WriteNames writes a list of names to w in one of the ListFormats
+// - columns: names in columns that fit within the width, followed by the total
+// - lines: one name per line
+// - json: an array of names
+// - csv: a "name" header, and then one name per line
+func WriteNames(w io.Writer, names []string, format string, width int) error {
+	switch format {
+	case "columns":
+		for _, line := range columns(names, width) {
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintf(w, "%d %s\n", len(names), "total names")
+	case "lines":
+		for _, name := range names {
+			fmt.Fprintln(w, name)
+		}
+	case "json":
+		return json.NewEncoder(w).Encode(names)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name"})
+		for _, name := range names {
+			cw.Write([]string{name})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("invalid format '%s', must be one of: %s", format, strings.Join(ListFormats, ", "))
+	}
+	return nil
+}
+
+// WriteCategories writes a list of categories to w in one of the ListFormats
+// - columns: the categories grouped by dimension, with their sprite & species counts, followed by the total
+// - lines: one category name per line
+// - json: an array of CategoryInfo objects
+// - csv: a header, and then one CategoryInfo per line
+func WriteCategories(w io.Writer, categories []CategoryInfo, format string) error {
+	switch format {
+	case "columns":
+		nameWidth := 0
+		for _, c := range categories {
+			if len(c.Name) > nameWidth {
+				nameWidth = len(c.Name)
+			}
+		}
+		for i, c := range categories {
+			if i == 0 || c.Dimension != categories[i-1].Dimension {
+				fmt.Fprintln(w, textStyleBold.Sprint(c.Dimension))
+			}
+			fmt.Fprintf(w, "  %-*s %5d sprites %5d species\n", nameWidth, c.Name, c.Sprites, c.Species)
+		}
+		fmt.Fprintf(w, "%d %s\n", len(categories), "total categories")
+	case "lines":
+		for _, c := range categories {
+			fmt.Fprintln(w, c.Name)
+		}
+	case "json":
+		return json.NewEncoder(w).Encode(categories)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"name", "dimension", "sprites", "species"})
+		for _, c := range categories {
+			cw.Write([]string{c.Name, c.Dimension, strconv.Itoa(c.Sprites), strconv.Itoa(c.Species)})
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("invalid format '%s', must be one of: %s", format, strings.Join(ListFormats, ", "))
+	}
+	return nil
+}
+
+// columns lays out words in as many columns as fit within the width, ordered down each column
+// (like ls)
+func columns(words []string, width int) []string {
+	if len(words) == 0 {
+		return []string{}
+	}
+	wordWidth := 0
+	for _, word := range words {
+		if len(word) > wordWidth {
+			wordWidth = len(word)
+		}
+	}
+	nColumns := (width + 2) / (wordWidth + 2)
+	if nColumns < 1 {
+		nColumns = 1
+	}
+	nRows := (len(words) + nColumns - 1) / nColumns
+
+	lines := make([]string, nRows)
+	for row := 0; row < nRows; row++ {
+		parts := make([]string, 0, nColumns)
+		for col := 0; col < nColumns; col++ {
+			if i := col*nRows + row; i < len(words) {
+				parts = append(parts, fmt.Sprintf("%-*s", wordWidth, words[i]))
+			}
+		}
+		lines[row] = strings.TrimRight(strings.Join(parts, "  "), " ")
+	}
+	return lines
+}
@@ -2,29 +2,123 @@ package pokesay
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
-	"log"
 	"math/rand"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tmck-code/pokesay/src/pokedex"
 )
 
 var (
-	Rand rand.Source = rand.NewSource(time.Now().UnixNano())
+	// The source of random numbers, which isn't safe for concurrent use, so it's only used via RandomInt
+	Rand     rand.Source = rand.NewSource(time.Now().UnixNano())
+	randLock sync.Mutex
+	// The error (wrapped with the name or category) when no pokemon can be chosen
+	ErrNotFound error = errors.New("cannot find pokemon")
 )
 
+// RandomInt returns a random number from 0 to n-1. It's safe to call from multiple goroutines,
+// e.g. the concurrent requests of ServeHandler
 func RandomInt(n int) int {
 	if n <= 0 {
 		return 0
 	}
+	randLock.Lock()
+	defer randLock.Unlock()
 	return rand.New(Rand).Intn(n)
 }
 
+// EntryFilter limits the pokemon entries that can be chosen, based on their rendered size.
+// A zero value for any field means that there is no limit
+// - Prefer narrows down the entries by category dimension, in order, e.g. "prefer gen8, and fall
+// back to gen7x". If none of the entries have a preferred value, then all of them are kept
+type EntryFilter struct {
+	MaxWidth  int
+	MaxHeight int
+	Prefer    []Preference
+}
+
+// Preference is an ordered list of preferred categories in a dimension, e.g. gen=gen8,gen7x
+type Preference struct {
+	Dimension string
+	Values    []string
+}
+
+// Matches returns true if the entry fits within the size limits of the filter
+func (f EntryFilter) Matches(entry pokedex.PokemonEntryMapping) bool {
+	return (f.MaxWidth <= 0 || entry.Width <= f.MaxWidth) &&
+		(f.MaxHeight <= 0 || entry.Height <= f.MaxHeight)
+}
+
+// Filter returns all of the entries that fit within the size limits of the filter, and that are
+// the most preferred
+func (f EntryFilter) Filter(entries []pokedex.PokemonEntryMapping) []pokedex.PokemonEntryMapping {
+	matching := make([]pokedex.PokemonEntryMapping, 0)
+	for _, entry := range entries {
+		if f.Matches(entry) {
+			matching = append(matching, entry)
+		}
+	}
+	for _, preference := range f.Prefer {
+		matching = preference.narrow(matching)
+	}
+	return matching
+}
+
+// narrow returns the entries with the first preferred value that any of the entries have, or all
+// of the entries if none of them have a preferred value
+func (p Preference) narrow(entries []pokedex.PokemonEntryMapping) []pokedex.PokemonEntryMapping {
+	for _, value := range p.Values {
+		preferred := make([]pokedex.PokemonEntryMapping, 0)
+		for _, entry := range entries {
+			if entry.Dimensions[p.Dimension] == value {
+				preferred = append(preferred, entry)
+			}
+		}
+		if len(preferred) > 0 {
+			return preferred
+		}
+	}
+	return entries
+}
+
+// ParsePreferences parses a list of preferences, e.g. from --prefer gen=gen8,gen7x,variant=shiny
+// - Each item that contains a "=" starts a new dimension
+// - Each item without a "=" is another value of the previous dimension
+func ParsePreferences(items []string) ([]Preference, error) {
+	preferences := make([]Preference, 0)
+	for _, item := range items {
+		if dimension, value, ok := strings.Cut(item, "="); ok {
+			preferences = append(preferences, Preference{dimension, []string{value}})
+		} else if len(preferences) > 0 {
+			last := &preferences[len(preferences)-1]
+			last.Values = append(last.Values, item)
+		} else {
+			return nil, fmt.Errorf("invalid preference '%s', must be like <dimension>=<category>[,<category>...]", item)
+		}
+	}
+	return preferences, nil
+}
+
+// ParseCategory parses a category that is either a plain category name (e.g. shiny), or qualified
+// with its dimension (e.g. variant=shiny), and returns the category name
+func ParseCategory(category string, index []pokedex.CategoryDimension) (string, error) {
+	dimension, value, ok := strings.Cut(category, "=")
+	if !ok {
+		return category, nil
+	}
+	if found := pokedex.FindDimension(index, value); found != dimension {
+		return "", fmt.Errorf("cannot find category '%s' in dimension '%s'", value, dimension)
+	}
+	return value, nil
+}
+
 // ChooseByCategory chooses a pokemon via a requested category
 // 1. It loads the category search structure and finds the name of a random Pokemon matching the entry
 // e.g. if given the category "small", this function might pick the file `1.cat` in
@@ -36,38 +130,66 @@ func RandomInt(n int) int {
 // This file contains entries representing the <pokemon metadata index>/<the pokemon entry index>,
 // e.g. "4/1" would represent 4.metadata, and the 2nd entry in that file
 // 2. Using the indexes, load the corresponding metadata file and entry, and then return it
-func ChooseByCategory(category string, categoryDir []fs.DirEntry, categoryFiles embed.FS, categoryRootDir string, metadataFiles embed.FS, metadataRootDir string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping) {
+// 3. If the entry doesn't match the filter, then the other category files are tried in a random order.
+// If the filter has preferences, then any entry of the pokemon in the category can be chosen
+// 4. If none of the entries in the category fit the filter, then an error is returned
+func ChooseByCategory(category string, categoryDir []fs.DirEntry, categoryFiles embed.FS, categoryRootDir string, metadataFiles embed.FS, metadataRootDir string, filter EntryFilter) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
 	if len(categoryDir) == 0 {
-		log.Fatalf("cannot find pokemon by category '%s'", category)
+		return pokedex.PokemonMetadata{}, pokedex.PokemonEntryMapping{}, fmt.Errorf("%w by category '%s'", ErrNotFound, category)
 	}
-	choice := categoryDir[RandomInt(len(categoryDir))]
+	start := RandomInt(len(categoryDir))
 
-	categoryMetadata, err := categoryFiles.ReadFile(
-		pokedex.CategoryFpath(categoryRootDir, category, choice.Name()),
-	)
-	pokedex.Check(err)
+	for i := range categoryDir {
+		choice := categoryDir[(start+i)%len(categoryDir)]
 
-	parts := strings.Split(string(categoryMetadata), "/")
+		categoryMetadata, err := categoryFiles.ReadFile(
+			pokedex.CategoryFpath(categoryRootDir, category, choice.Name()),
+		)
+		if err != nil {
+			return pokedex.PokemonMetadata{}, pokedex.PokemonEntryMapping{}, err
+		}
 
-	metadata := pokedex.ReadMetadataFromEmbedded(
-		metadataFiles,
-		path.Join(metadataRootDir, fmt.Sprintf("%s.metadata", parts[0])),
-	)
+		parts := strings.Split(string(categoryMetadata), "/")
+
+		metadata := pokedex.ReadMetadataFromEmbedded(
+			metadataFiles,
+			path.Join(metadataRootDir, fmt.Sprintf("%s.metadata", parts[0])),
+		)
 
-	entryIndex, err := strconv.Atoi(string(parts[1]))
-	pokedex.Check(err)
+		entryIndex, err := strconv.Atoi(string(parts[1]))
+		if err != nil {
+			return pokedex.PokemonMetadata{}, pokedex.PokemonEntryMapping{}, err
+		}
 
-	return metadata, metadata.Entries[entryIndex]
+		if len(filter.Prefer) == 0 {
+			if filter.Matches(metadata.Entries[entryIndex]) {
+				return metadata, metadata.Entries[entryIndex], nil
+			}
+			continue
+		}
+		// with preferences, choose from all of the entries of this pokemon in the category, so that
+		// the preferred entries can be chosen
+		candidates := make([]pokedex.PokemonEntryMapping, 0)
+		for _, entry := range metadata.Entries {
+			if pokedex.ContainsString(entry.Categories, category) {
+				candidates = append(candidates, entry)
+			}
+		}
+		if matching := filter.Filter(candidates); len(matching) > 0 {
+			return metadata, matching[RandomInt(len(matching))], nil
+		}
+	}
+	return pokedex.PokemonMetadata{}, pokedex.PokemonEntryMapping{}, fmt.Errorf("%w by category '%s' that fits the size limits", ErrNotFound, category)
 }
 
 func ListNames(names map[string][]int) []string {
 	return pokedex.GatherMapKeys(names)
 }
 
-func fetchMetadataByName(names map[string][]int, nameToken string, metadataFiles embed.FS, metadataRootDir string) pokedex.PokemonMetadata {
+func fetchMetadataByName(names map[string][]int, nameToken string, metadataFiles embed.FS, metadataRootDir string) (pokedex.PokemonMetadata, error) {
 	match := names[nameToken]
 	if len(match) == 0 {
-		log.Fatalf("cannot find pokemon by name '%s'", nameToken)
+		return pokedex.PokemonMetadata{}, fmt.Errorf("%w by name '%s'", ErrNotFound, nameToken)
 	}
 	nameChoice := match[RandomInt(len(match))]
 
@@ -75,35 +197,48 @@ func fetchMetadataByName(names map[string][]int, nameToken string, metadataFiles
 		metadataFiles,
 		pokedex.MetadataFpath(metadataRootDir, nameChoice),
 	)
-	return metadata
-
+	return metadata, nil
 }
 
-func ChooseByName(names map[string][]int, nameToken string, metadataFiles embed.FS, metadataRootDir string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping) {
-	metadata := fetchMetadataByName(
+func ChooseByName(names map[string][]int, nameToken string, metadataFiles embed.FS, metadataRootDir string, filter EntryFilter) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	metadata, err := fetchMetadataByName(
 		names,
 		nameToken,
 		metadataFiles,
 		metadataRootDir,
 	)
+	if err != nil {
+		return metadata, pokedex.PokemonEntryMapping{}, err
+	}
 
-	// pick a random entry
-	choice := RandomInt(len(metadata.Entries))
-	return metadata, metadata.Entries[choice]
+	// pick a random entry that fits the size limits
+	matching := filter.Filter(metadata.Entries)
+	if len(matching) == 0 {
+		return metadata, pokedex.PokemonEntryMapping{}, fmt.Errorf("%w by name '%s' that fits the size limits", ErrNotFound, nameToken)
+	}
+	return metadata, matching[RandomInt(len(matching))], nil
 }
 
-func ChooseByNameAndCategory(names map[string][]int, nameToken string, metadataFiles embed.FS, metadataRootDir string, category string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping) {
+func ChooseByNameAndCategory(names map[string][]int, nameToken string, metadataFiles embed.FS, metadataRootDir string, category string, filter EntryFilter) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
 	// fetch the metadata of a pokemon matching the nameToken
-	metadata := fetchMetadataByName(
+	metadata, err := fetchMetadataByName(
 		names,
 		nameToken,
 		metadataFiles,
 		metadataRootDir,
 	)
+	if err != nil {
+		return metadata, pokedex.PokemonEntryMapping{}, err
+	}
+
+	entries := filter.Filter(metadata.Entries)
+	if len(entries) == 0 {
+		return metadata, pokedex.PokemonEntryMapping{}, fmt.Errorf("%w by name '%s' that fits the size limits", ErrNotFound, nameToken)
+	}
 
 	// now try and find a metadata entry that matches the requested category
 	matching := make([]pokedex.PokemonEntryMapping, 0)
-	for _, entry := range metadata.Entries {
+	for _, entry := range entries {
 		for _, entryCategory := range entry.Categories {
 			if entryCategory == category {
 				matching = append(matching, entry)
@@ -113,9 +248,9 @@ func ChooseByNameAndCategory(names map[string][]int, nameToken string, metadataF
 
 	// if the category is not found for this pokemon, return a random entry
 	if len(matching) == 0 {
-		return metadata, metadata.Entries[RandomInt(len(metadata.Entries))]
+		return metadata, entries[RandomInt(len(entries))], nil
 	} else {
-		return metadata, matching[RandomInt(len(matching))]
+		return metadata, matching[RandomInt(len(matching))], nil
 	}
 }
 
@@ -123,3 +258,23 @@ func ChooseByRandomIndex(totalInBytes []byte) (int, int) {
 	total := pokedex.ReadIntFromBytes(totalInBytes)
 	return total, RandomInt(total)
 }
+
+// ChooseRandom chooses a random pokemon entry that fits the filter
+// - It chooses a random metadata index between 0 and the total, and reads the metadata file
+// - If none of the metadata entries fit the filter, then the following metadata files are tried in order
+// - If none of the pokemon fit the filter, then an error is returned
+func ChooseRandom(totalInBytes []byte, metadataFiles embed.FS, metadataRootDir string, filter EntryFilter) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+	total, start := ChooseByRandomIndex(totalInBytes)
+
+	for i := 0; i < total; i++ {
+		metadata := pokedex.ReadMetadataFromEmbedded(
+			metadataFiles,
+			pokedex.MetadataFpath(metadataRootDir, (start+i)%total),
+		)
+		matching := filter.Filter(metadata.Entries)
+		if len(matching) > 0 {
+			return metadata, matching[RandomInt(len(matching))], nil
+		}
+	}
+	return pokedex.PokemonMetadata{}, pokedex.PokemonEntryMapping{}, fmt.Errorf("%w that fits the size limits", ErrNotFound)
+}
@@ -0,0 +1,149 @@
+package pokesay
+
+import (
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var (
+	// The colours that can be used in markup tags, e.g. [red]text[/]
+	MarkupColours map[string]color.Attribute = map[string]color.Attribute{
+		"black":          color.FgBlack,
+		"red":            color.FgRed,
+		"green":          color.FgGreen,
+		"yellow":         color.FgYellow,
+		"blue":           color.FgBlue,
+		"magenta":        color.FgMagenta,
+		"cyan":           color.FgCyan,
+		"white":          color.FgWhite,
+		"bright-black":   color.FgHiBlack,
+		"bright-red":     color.FgHiRed,
+		"bright-green":   color.FgHiGreen,
+		"bright-yellow":  color.FgHiYellow,
+		"bright-blue":    color.FgHiBlue,
+		"bright-magenta": color.FgHiMagenta,
+		"bright-cyan":    color.FgHiCyan,
+		"bright-white":   color.FgHiWhite,
+	}
+)
+
+// markupState is the styles that are active while rendering a line of markup
+type markupState struct {
+	bold, italic, code bool
+	colours            []color.Attribute // a stack of the open colour tags
+}
+
+// attributes returns the text attributes of the active styles
+func (s markupState) attributes() []color.Attribute {
+	attrs := make([]color.Attribute, 0)
+	if s.bold {
+		attrs = append(attrs, color.Bold)
+	}
+	if s.italic {
+		attrs = append(attrs, color.Italic)
+	}
+	if s.code {
+		attrs = append(attrs, color.ReverseVideo)
+	}
+	if len(s.colours) > 0 {
+		attrs = append(attrs, s.colours[len(s.colours)-1])
+	}
+	return attrs
+}
+
+// RenderMarkup renders a line of lightweight markup with the same text styles as the info box
+// - **bold**, *italic* and `code` (no other markup is rendered inside code)
+// - [red]colour tags[/], using the MarkupColours. The [/] closes the most recent colour tag
+// - A backslash before a markup character prints it as-is, e.g. \*not italic\*
+//
+// Markup that isn't closed on the same line, and tags that aren't colours (e.g. [1]), are printed
+// as-is. Each run of styled text is reset after it, so that the styles can be nested, and the
+// line can be wrapped without any styles leaking onto the next line.
+func RenderMarkup(line string) string {
+	var out, run strings.Builder
+	state := markupState{}
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if attrs := state.attributes(); len(attrs) > 0 {
+			out.WriteString(color.New(attrs...).Sprint(run.String()))
+		} else {
+			out.WriteString(run.String())
+		}
+		run.Reset()
+	}
+
+	for i := 0; i < len(line); i++ {
+		rest := line[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.ContainsRune("\\*`[", rune(rest[1])):
+			run.WriteByte(rest[1])
+			i++
+		case rest[0] == '`':
+			end := strings.IndexByte(rest[1:], '`')
+			if end < 0 {
+				run.WriteByte('`')
+				continue
+			}
+			flush()
+			state.code = true
+			run.WriteString(rest[1 : end+1])
+			flush()
+			state.code = false
+			i += end + 1
+		case strings.HasPrefix(rest, "**") && (state.bold || opensEmphasis(rest, "**")):
+			flush()
+			state.bold = !state.bold
+			i++
+		case rest[0] == '*' && (state.italic || opensEmphasis(rest, "*")):
+			flush()
+			state.italic = !state.italic
+		case strings.HasPrefix(rest, "[/]") && len(state.colours) > 0:
+			flush()
+			state.colours = state.colours[:len(state.colours)-1]
+			i += 2
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				run.WriteByte('[')
+				continue
+			}
+			colour, ok := MarkupColours[rest[1:end]]
+			if !ok || !strings.Contains(rest[end:], "[/]") {
+				run.WriteByte('[')
+				continue
+			}
+			flush()
+			state.colours = append(state.colours, colour)
+			i += end
+		default:
+			run.WriteByte(rest[0])
+		}
+	}
+	flush()
+	return out.String()
+}
+
+// EscapeMarkup escapes the markup characters in text, so that RenderMarkup prints it as-is
+func EscapeMarkup(text string) string {
+	return strings.NewReplacer("\\", "\\\\", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
+}
+
+// opensEmphasis returns whether a "*" or "**" delimiter at the start of s opens emphasis, i.e. it's
+// followed by some text, and then a closing delimiter (that isn't escaped) later in the line
+func opensEmphasis(s string, delimiter string) bool {
+	rest := s[len(delimiter):]
+	if rest == "" || rest[0] == ' ' || (delimiter == "*" && rest[0] == '*') {
+		return false
+	}
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '\\' {
+			i++
+		} else if strings.HasPrefix(rest[i:], delimiter) {
+			return true
+		}
+	}
+	return false
+}
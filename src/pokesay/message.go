@@ -0,0 +1,60 @@
+package pokesay
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pborman/getopt/v2"
+)
+
+// ParseParams parses the flags in argv, which can be given before or after the positional
+// parameters, and returns the positional parameters
+// - Everything after a "--" is a positional parameter, e.g. `pokesay -- -n is not a flag`
+// - If the -h/--help flag is given, then parsing stops, and help is true
+func ParseParams(set *getopt.Set, argv []string) (params []string, help bool) {
+	helpOption := set.Lookup("help")
+	params = make([]string, 0)
+	for {
+		set.Parse(argv)
+		rest := set.Args()
+		if helpOption != nil && helpOption.Seen() {
+			return params, true
+		}
+		if len(rest) == 0 {
+			return params, false
+		}
+		if argv[len(argv)-len(rest)-1] == "--" {
+			return append(params, rest...), false
+		}
+		params = append(params, rest[0])
+		argv = append([]string{argv[0]}, rest[1:]...)
+	}
+}
+
+// ResolveMessage returns the reader that the text for the speech bubble is read from, in order of
+// precedence
+// - The message (i.e. the positional arguments), joined with spaces like cowsay, e.g. `pokesay hello world`
+// - The file, or STDIN if the file is "-"
+// - STDIN, or a random fortune if fortune is true or there's nothing to read (see MessageInput)
+//
+// An error is returned if fortune is true along with a message or file, or the file can't be opened.
+func ResolveMessage(message []string, file string, fortune bool, stdin *os.File, fortunes func() []string) (io.Reader, error) {
+	if fortune && (len(message) > 0 || file != "") {
+		return nil, errors.New("cannot use --fortune with a message or --file")
+	}
+	if len(message) > 0 {
+		return strings.NewReader(strings.Join(message, " ") + "\n"), nil
+	}
+	if file == "-" {
+		return stdin, nil
+	} else if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return MessageInput(stdin, fortune, fortunes), nil
+}
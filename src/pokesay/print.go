@@ -1,15 +1,13 @@
 package pokesay
 
 import (
-	"bufio"
 	"embed"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/fatih/color"
-	"github.com/mattn/go-runewidth"
-	"github.com/mitchellh/go-wordwrap"
 	"github.com/tmck-code/pokesay/src/pokedex"
 )
 
@@ -20,36 +18,71 @@ type BoxChars struct {
 	TopLeftCorner     string
 	BottomRightCorner string
 	BottomLeftCorner  string
-	BalloonString     string
+	BalloonString     string // the string below a speech bubble, going down & right
+	BalloonStringLeft string // the string below a speech bubble, going down & left
+	BalloonStringDown string // the string below a speech bubble, going straight down
 	BalloonTether     string
 	Separator         string
 	RightArrow        string
 	CategorySeparator string
+	TitleLeft         string // the characters before a title or footer in the speech bubble border
+	TitleRight        string // the characters after a title or footer in the speech bubble border
+	Ellipsis          string // the characters at the end of a title or footer that was cut off
 }
 
 type Args struct {
 	Width          int
 	NoWrap         bool
+	Wrap           string
+	Align          string
 	DrawBubble     bool
 	TabSpaces      string
 	NoTabSpaces    bool
+	StripInputANSI bool
+	Markup         bool
+	Title          string
+	Footer         string
+	MaxLines       int
+	Head           bool
+	Tail           bool
+	Output         io.Writer
+	HeadColumn     int            // the column of the top of the pokemon's head (after the SpriteOffset), that the tether points at (0 for the default)
+	SpriteWidth    int            // the width of the pokemon, from its metadata (see pokedex.PokemonEntryMapping), that it's aligned with
+	SpriteAlign    string         // how to align the pokemon, one of SpriteAlignments
+	SpriteAlignTo  string         // what the pokemon is aligned within, one of SpriteAlignTargets
+	Indent         int            // the number of spaces before the pokemon
+	ColourFilters  []ColourFilter // the colour filters that are applied to the pokemon, in order
+	Background     string         // the background of the terminal, one of Backgrounds ("auto" is treated as "dark" until it's resolved with DetectBackground)
 	NoCategoryInfo bool
 	ListCategories bool
 	ListNames      bool
+	ListFormat     string
 	Category       string
-	NameToken      string
+	NameTokens     []string
+	Count          int
+	SplitDelimiter string
 	JapaneseName   bool
+	Languages      []string // the languages to print the name in, in order, see pokedex.Languages
 	BoxChars       *BoxChars
 	DrawInfoBorder bool
+	InfoDimensions []string
+	InfoFormat     string // the template of the info box, see ExpandInfoFormat (DefaultInfoFormat if empty)
+	InfoPosition   string // where the info box is printed, one of InfoPositions
+	Filter         EntryFilter
+	Input          io.Reader
+	Animate        string
+	FPS            int
 	Help           bool
 	Verbose        bool
 }
 
 var (
-	textStyleItalic *color.Color = color.New(color.Italic)
-	textStyleBold   *color.Color = color.New(color.Bold)
-	resetColourANSI string       = "\033[0m"
-	AsciiBoxChars   *BoxChars    = &BoxChars{
+	textStyleItalic  *color.Color = color.New(color.Italic)
+	textStyleBold    *color.Color = color.New(color.Bold)
+	textStyleReverse *color.Color = color.New(color.ReverseVideo)
+	resetColourANSI  string       = "\033[0m"
+	SpriteRoot       string       = "build/assets/cows" // the directory of the sprites in the embedded cow data
+	AsciiBoxChars    *BoxChars    = &BoxChars{
 		HorizontalEdge:    "-",
 		VerticalEdge:      "|",
 		TopRightCorner:    "\\",
@@ -57,10 +90,15 @@ var (
 		BottomRightCorner: "/",
 		BottomLeftCorner:  "\\",
 		BalloonString:     "\\",
+		BalloonStringLeft: "/",
+		BalloonStringDown: "|",
 		BalloonTether:     "¡",
 		Separator:         "|",
 		RightArrow:        ">",
 		CategorySeparator: "/",
+		TitleLeft:         "[ ",
+		TitleRight:        " ]",
+		Ellipsis:          "...",
 	}
 	UnicodeBoxChars *BoxChars = &BoxChars{
 		HorizontalEdge:    "─",
@@ -70,17 +108,47 @@ var (
 		BottomRightCorner: "╯",
 		BottomLeftCorner:  "╰",
 		BalloonString:     "╲",
+		BalloonStringLeft: "╱",
+		BalloonStringDown: "│",
 		BalloonTether:     "╲",
 		Separator:         "│",
 		RightArrow:        "→",
 		CategorySeparator: "/",
+		TitleLeft:         "┤ ",
+		TitleRight:        " ├",
+		Ellipsis:          "…",
 	}
-	SingleWidthChars map[string]bool = map[string]bool{
-		"♀": true,
-		"♂": true,
-	}
 )
 
+var (
+	SpriteAlignments   []string = []string{"left", "center", "right"}
+	SpriteAlignTargets []string = []string{"bubble", "terminal"}
+	DefaultIndent      int      = 4 // the number of spaces before a pokemon, unless --indent is given
+)
+
+var (
+	DefaultHeadColumn   int = 12 // the column that the tether points at, if the head column of a pokemon isn't known
+	DefaultTetherLength int = 4  // the number of lines in the string below a speech bubble
+	MinTetherLength     int = 2  // the shortest string, when the head is close to the left of the bubble
+	MaxTetherLength     int = 8  // the longest string, when the head is far to the right of the bubble
+)
+
+// EntryCategories returns the categories of an entry to print in the info box
+// - If no dimensions are given, then all of the categories are returned
+// - Otherwise, the category in each of the dimensions is returned, in order
+func EntryCategories(entry pokedex.PokemonEntryMapping, dimensions []string) []string {
+	if len(dimensions) == 0 {
+		return entry.Categories
+	}
+	categories := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		if category, ok := entry.Dimensions[dimension]; ok {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
 func DetermineBoxChars(unicodeBox bool) *BoxChars {
 	if unicodeBox {
 		return UnicodeBoxChars
@@ -89,74 +157,344 @@ func DetermineBoxChars(unicodeBox bool) *BoxChars {
 	}
 }
 
-// The main print function! This uses a chosen pokemon's index, names and categories, and an
-// embedded filesystem of cowfile data
+// The main print function! This uses a chosen pokemon's index and info, and an embedded
+// filesystem of cowfile data
 // 1. The text received from STDIN is printed inside a speech bubble
 // 2. The cowfile data is retrieved using the matching index, decompressed (un-gzipped),
 // 3. The pokemon is printed along with the name & category information
-func Print(args Args, choice int, names []string, categories []string, cows embed.FS) {
-	printSpeechBubble(args.BoxChars, bufio.NewScanner(os.Stdin), args)
-	printPokemon(args, choice, names, categories, cows)
+//
+// An error is returned if the text can't be read.
+func Print(args Args, choice int, info PokemonInfo, cows embed.FS) error {
+	if args.Animate != "" {
+		return Animate(args, choice, info, cows)
+	}
+	return Fprint(args.output(), args.input(), args, choice, info, cows)
 }
 
-// Prints text from STDIN, surrounded by a speech bubble.
-func printSpeechBubble(boxChars *BoxChars, scanner *bufio.Scanner, args Args) {
+// input returns the reader that the text for the speech bubble is read from, which is STDIN if
+// no other input is set
+func (args Args) input() io.Reader {
+	if args.Input == nil {
+		return os.Stdin
+	}
+	return args.Input
+}
+
+// output returns the writer that pokemon are printed to, which is STDOUT if no other output is set
+func (args Args) output() io.Writer {
+	if args.Output == nil {
+		return os.Stdout
+	}
+	return args.Output
+}
+
+// Fprint prints a pokemon to w, with the text read from r inside a speech bubble
+// - If the text can't be read, then the error is returned, and the pokemon isn't printed
+func Fprint(w io.Writer, r io.Reader, args Args, choice int, info PokemonInfo, cows embed.FS) error {
+	if err := printSpeechBubble(w, args.BoxChars, r, args); err != nil {
+		return err
+	}
+	printPokemon(w, args, choice, info, cows)
+	return nil
+}
+
+// PrintPokemon prints a pokemon along with its name & category information, without reading any
+// text from STDIN or drawing a speech bubble
+func PrintPokemon(args Args, choice int, info PokemonInfo, cows embed.FS) {
+	printPokemon(args.output(), args, choice, info, cows)
+}
+
+// Prints text from STDIN, surrounded by a speech bubble. Returns the error if the text can't be read
+func printSpeechBubble(w io.Writer, boxChars *BoxChars, r io.Reader, args Args) error {
 	if args.DrawBubble {
-		fmt.Printf(
+		fmt.Fprintf(
+			w,
 			"%s%s%s\n",
 			boxChars.TopLeftCorner,
-			strings.Repeat(boxChars.HorizontalEdge, args.Width+2),
+			BorderWithLabel(boxChars, args.Width+2, SanitiseLine(args.Title, true), false),
 			boxChars.TopRightCorner,
 		)
 	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-
+	// the lines are kept by the limit (if any), and printed after all of the text has been read
+	var limit *LineLimit
+	if args.MaxLines > 0 {
+		limit = NewLineLimit(args.MaxLines, args.Head, args.Tail)
+	}
+	printLine := func(line string) {
 		if !args.NoTabSpaces {
 			line = strings.Replace(line, "\t", args.TabSpaces, -1)
 		}
-		if args.NoWrap {
-			printSpeechBubbleLine(boxChars, line, args)
-		} else {
-			printWrappedText(boxChars, line, args)
+		if args.Markup {
+			line = RenderMarkup(line)
+			if args.Background == "light" {
+				line = ReadableOnLight(line)
+			}
+		}
+		wrapped := []string{line}
+		if !args.NoWrap && args.Wrap != "none" {
+			wrapped = WrapText(line, args.Width, args.Wrap)
+		}
+		for _, wline := range wrapped {
+			if limit != nil {
+				limit.Add(wline)
+			} else {
+				printSpeechBubbleLine(w, boxChars, wline, args)
+			}
 		}
 	}
 
-	bottomBorder := strings.Repeat(boxChars.HorizontalEdge, 6) +
+	lines := NewLineReader(r, args.StripInputANSI)
+	footer := SanitiseLine(args.Footer, true)
+	if args.Footer == "auto" {
+		// only the lines from the last non-empty line onwards are needed to find the attribution at
+		// the end, so the earlier lines are printed as they're read (and kept within the limit)
+		var printed string // the last line that was printed, which isn't empty
+		pending, lastText := make([]string, 0), -1
+		for lines.Scan() {
+			line := lines.Text()
+			if strings.TrimSpace(line) != "" {
+				for _, p := range pending[:lastText+1] {
+					printLine(p)
+				}
+				if lastText >= 0 {
+					printed = pending[lastText]
+				}
+				pending, lastText = append(pending[:0], pending[lastText+1:]...), len(pending)-lastText-1
+			}
+			pending = append(pending, line)
+		}
+		// the last printed line is included, so that the attribution isn't found on the first line
+		if printed != "" {
+			pending = append([]string{printed}, pending...)
+		}
+		text, author := FindAttribution(pending)
+		if printed != "" {
+			text = text[1:]
+		}
+		for _, line := range text {
+			printLine(line)
+		}
+		footer = author
+	} else {
+		for lines.Scan() {
+			printLine(lines.Text())
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return err
+	}
+
+	tether, stringColumns := TetherRoute(args.HeadColumn, args.Width+4)
+	if limit != nil {
+		// the number of dropped lines is put in the footer, or on the ellipsis line (shortened if
+		// needed) if it doesn't fit
+		ellipsis := boxChars.Ellipsis
+		if limit.Dropped() > 0 {
+			unit := "lines"
+			if limit.Dropped() == 1 {
+				unit = "line"
+			}
+			count := fmt.Sprintf("(+%d more %s)", limit.Dropped(), unit)
+			if label, ok := moreLinesLabel(boxChars, args.Width+2-tether, count, footer); ok {
+				footer = label
+			} else if UnicodeStringLength(ellipsis+" "+count) <= args.Width {
+				ellipsis += " " + count
+			} else {
+				ellipsis += fmt.Sprintf(" +%d", limit.Dropped())
+			}
+		}
+		for _, line := range limit.Lines(ellipsis) {
+			printSpeechBubbleLine(w, boxChars, line, args)
+		}
+	}
+
+	bottomBorder := strings.Repeat(boxChars.HorizontalEdge, tether-1) +
 		boxChars.BalloonTether +
-		strings.Repeat(boxChars.HorizontalEdge, args.Width+2-7)
+		BorderWithLabel(boxChars, args.Width+2-tether, footer, true)
 
 	if args.DrawBubble {
-		fmt.Printf("%s%s%s\n", boxChars.BottomLeftCorner, bottomBorder, boxChars.BottomRightCorner)
+		fmt.Fprintf(w, "%s%s%s\n", boxChars.BottomLeftCorner, bottomBorder, boxChars.BottomRightCorner)
 	} else {
-		fmt.Printf(" %s \n", bottomBorder)
+		fmt.Fprintf(w, " %s \n", bottomBorder)
+	}
+	previous := tether
+	for _, column := range stringColumns {
+		balloonString := boxChars.BalloonStringDown
+		if column > previous {
+			balloonString = boxChars.BalloonString
+		} else if column < previous {
+			balloonString = boxChars.BalloonStringLeft
+		}
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat(" ", column), balloonString)
+		previous = column
+	}
+	return nil
+}
+
+// TetherRoute returns the route of a speech bubble's tether, from the bubble's bottom border down to
+// the top of the pokemon's head
+// - The first value is the column of the tether in the bottom border, which is width columns wide
+// (including the corners)
+// - The second value is the column of each line of the string below the border. The string runs
+// diagonally towards the head, and then straight down if it reaches the head before it ends
+// - The string is DefaultTetherLength lines long, but it's longer if the head is far to the right
+// of the bubble, and shorter if the head is close to the left, between MinTetherLength and
+// MaxTetherLength lines
+//
+// If the head column is 0 (i.e. not known), then DefaultHeadColumn is used.
+func TetherRoute(head int, width int) (int, []int) {
+	if head <= 0 {
+		head = DefaultHeadColumn
+	}
+	// the tether can't be on the corners of the border
+	tether := head - DefaultTetherLength - 1
+	if tether > width-2 {
+		tether = width - 2
+	}
+	if tether < 1 {
+		tether = 1
+	}
+
+	length := head - tether - 1
+	if head < tether {
+		length = tether - head - 1
+	}
+	if length < MinTetherLength {
+		length = MinTetherLength
+	} else if length > MaxTetherLength {
+		length = MaxTetherLength
 	}
-	for i := 0; i < 4; i++ {
-		fmt.Printf("%s%s\n", strings.Repeat(" ", i+8), boxChars.BalloonString)
+
+	columns := make([]int, length)
+	column := tether
+	for i := range columns {
+		if column < head {
+			column++
+		} else if column > head {
+			column--
+		}
+		columns[i] = column
+	}
+	return tether, columns
+}
+
+// BorderWithLabel returns a horizontal edge of a speech bubble that is width columns wide, with a
+// label (e.g. a title) embedded in it, surrounded by the TitleLeft & TitleRight characters
+// - The label is put after the first column, or before the last column if alignRight is true
+// - A label that doesn't fit is cut off, and ends with the Ellipsis characters
+// - If the label is empty, or there isn't room for at least 1 character of it, then the edge is plain
+func BorderWithLabel(boxChars *BoxChars, width int, label string, alignRight bool) string {
+	label = truncateLabel(boxChars, label, labelWidth(boxChars, width))
+	if label == "" {
+		return strings.Repeat(boxChars.HorizontalEdge, width)
+	}
+	label = boxChars.TitleLeft + label + boxChars.TitleRight
+	edge := strings.Repeat(boxChars.HorizontalEdge, width-1-UnicodeStringLength(label))
+	if alignRight {
+		return edge + label + boxChars.HorizontalEdge
 	}
+	return boxChars.HorizontalEdge + label + edge
+}
+
+// labelWidth returns the number of columns that a label can take up in a border that is width columns
+// wide, leaving at least 1 edge column either side of the label
+func labelWidth(boxChars *BoxChars, width int) int {
+	return width - 2 - UnicodeStringLength(boxChars.TitleLeft) - UnicodeStringLength(boxChars.TitleRight)
 }
 
-// Prints a single speech bubble line
-func printSpeechBubbleLine(boxChars *BoxChars, line string, args Args) {
+// truncateLabel cuts off a label that is wider than the available columns, so that it ends with the
+// Ellipsis characters. If there isn't room for at least 1 character of it, then it's empty
+func truncateLabel(boxChars *BoxChars, label string, available int) string {
+	if label == "" || available < 1 {
+		return ""
+	}
+	if UnicodeStringLength(label) > available {
+		n := available - UnicodeStringLength(boxChars.Ellipsis)
+		if n < 1 {
+			return ""
+		}
+		label = label[:prefixLength(label, n)] + boxChars.Ellipsis
+	}
+	return label
+}
+
+// moreLinesLabel returns the footer of a speech bubble that had lines dropped by --max-lines, i.e.
+// the count of the dropped lines (e.g. "(+3 more lines)") followed by the footer, for a bottom
+// border that is width columns wide
+// - The count is never cut off, only the footer after it is cut off if it's too long
+// - If there isn't room for the whole count, then ok is false
+func moreLinesLabel(boxChars *BoxChars, width int, count string, footer string) (string, bool) {
+	available := labelWidth(boxChars, width)
+	if UnicodeStringLength(count) > available {
+		return footer, false
+	}
+	return strings.TrimSpace(count + " " + truncateLabel(boxChars, footer, available-UnicodeStringLength(count)-1)), true
+}
+
+// FindAttribution finds a fortune-style attribution (e.g. "  -- Oscar Wilde") on the last
+// non-empty line of some text
+// - It returns the rest of the text, and the attribution without the dashes (e.g. "Oscar Wilde")
+// - If there is no attribution, or it's the only line, then the text is returned unchanged, along
+// with an empty attribution
+func FindAttribution(lines []string) ([]string, string) {
+	last := len(lines) - 1
+	for last >= 0 && strings.TrimSpace(lines[last]) == "" {
+		last--
+	}
+	if last < 1 {
+		return lines, ""
+	}
+	line := strings.TrimSpace(SanitiseLine(lines[last], true))
+	for _, dash := range []string{"--", "—", "―"} {
+		// e.g. a line of dashes isn't an attribution
+		if author := strings.TrimSpace(strings.TrimPrefix(line, dash)); strings.HasPrefix(line, dash) && strings.Trim(author, "-—―") != "" {
+			rest := lines[:last]
+			for len(rest) > 1 && strings.TrimSpace(rest[len(rest)-1]) == "" {
+				rest = rest[:len(rest)-1]
+			}
+			return rest, author
+		}
+	}
+	return lines, ""
+}
+
+// Prints a single speech bubble line, aligned within the width of the bubble
+func printSpeechBubbleLine(w io.Writer, boxChars *BoxChars, line string, args Args) {
+	lineLen := UnicodeStringLength(line)
+	padding := 0
+	if lineLen < args.Width {
+		padding = args.Width - lineLen
+	}
+	// split the padding either side of the text, depending on the alignment
+	left := 0
+	switch args.Align {
+	case "center":
+		left = padding / 2
+	case "right":
+		left = padding
+	}
+
 	if !args.DrawBubble {
-		fmt.Println(line)
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat(" ", left), line)
 		return
 	}
 
-	lineLen := UnicodeStringLength(line)
 	if lineLen <= args.Width {
 		// print the line with padding, the most common case
-		fmt.Printf(
-			"%s %s%s%s %s\n",
-			boxChars.VerticalEdge, // left-hand side of the bubble
-			line, resetColourANSI, // the text
-			strings.Repeat(" ", args.Width-lineLen), // padding
-			boxChars.VerticalEdge,                   // right-hand side of the bubble
+		fmt.Fprintf(
+			w,
+			"%s %s%s%s%s %s\n",
+			boxChars.VerticalEdge,     // left-hand side of the bubble
+			strings.Repeat(" ", left), // padding before the text
+			line, resetColourANSI,     // the text
+			strings.Repeat(" ", padding-left), // padding after the text
+			boxChars.VerticalEdge,             // right-hand side of the bubble
 		)
 	} else if lineLen > args.Width {
 		// print the line without padding or right-hand side of the bubble if the line is too long
-		fmt.Printf(
+		fmt.Fprintf(
+			w,
 			"%s %s%s\n",
 			boxChars.VerticalEdge, // left-hand side of the bubble
 			line, resetColourANSI, // the text
@@ -164,110 +502,113 @@ func printSpeechBubbleLine(boxChars *BoxChars, line string, args Args) {
 	}
 }
 
-// Prints line of text across multiple lines, wrapping it so that it doesn't exceed the desired width.
-func printWrappedText(boxChars *BoxChars, line string, args Args) {
-	for _, wline := range strings.Split(wordwrap.WrapString(strings.Replace(line, "\t", args.TabSpaces, -1), uint(args.Width)), "\n") {
-		printSpeechBubbleLine(boxChars, wline, args)
-	}
+// Returns the width of a string in terminal columns, taking into account Unicode characters and escape sequences.
+func UnicodeStringLength(s string) int {
+	return pokedex.UnicodeStringLength(s)
 }
 
-func nameLength(names []string) int {
-	totalLen := 0
+// Prints a pokemon with its info box, e.g. its name & category information.
+func printPokemon(w io.Writer, args Args, index int, info PokemonInfo, GOBCowData embed.FS) {
+	sprite := strings.Split(strings.TrimRight(string(renderSprite(args, index, GOBCowData)), "\n"), "\n")
+	for _, line := range LayoutPokemon(args, sprite, RenderInfoBox(args, info)) {
+		fmt.Fprintln(w, line)
+	}
+}
 
-	for _, name := range names {
-		for _, c := range name {
-			// check if ascii or single-width unicode
-			if (c < 128) || (SingleWidthChars[string(c)]) {
-				totalLen++
-			} else {
-				totalLen += 2
-			}
-		}
+// Reads the cowfile data of a pokemon, applies any colour filters (and the LightBackground filter on
+// a light background), and positions it with the sprite alignment & indent, for a sprite that is
+// args.SpriteWidth columns wide
+func renderSprite(args Args, index int, GOBCowData embed.FS) []byte {
+	filters := args.ColourFilters
+	if args.Background == "light" {
+		filters = append(append([]ColourFilter{}, filters...), LightBackground)
+	}
+	sprite := FilterSprite(readSprite(index, GOBCowData), filters)
+	return IndentSprite(sprite, SpriteOffset(args, args.SpriteWidth))
+}
 
+// SpriteOffset returns the number of columns before a pokemon sprite that is width columns wide
+// - The sprite is aligned (see SpriteAlignments) within the speech bubble, or the terminal if
+// SpriteAlignTo is "terminal"
+// - The indent is always before the sprite, i.e. the sprite is aligned within the rest of the width
+func SpriteOffset(args Args, width int) int {
+	area := args.Width + 4 // the bubble edges & padding, e.g. "| " & " |"
+	if args.SpriteAlignTo == "terminal" {
+		area = TerminalWidth()
+	}
+	space := area - args.Indent - width
+	if space < 0 {
+		space = 0
+	}
+	switch args.SpriteAlign {
+	case "center":
+		return args.Indent + space/2
+	case "right":
+		return args.Indent + space
+	default:
+		return args.Indent
 	}
-	return totalLen
 }
 
-// Returns the length of a string, taking into account Unicode characters and ANSI escape codes.
-func UnicodeStringLength(s string) int {
-	nRunes, totalLen, ansiCode := len(s), 0, false
-
-	for i, r := range s {
-		if i < nRunes-1 {
-			// detect the beginning of an ANSI escape code
-			// e.g. "\033[38;5;196m"
-			//       ^^^ start    ^ end
-			if s[i:i+2] == "\033[" {
-				ansiCode = true
-			}
-		}
-		if ansiCode {
-			// detect the end of an ANSI escape code
-			if r == 'm' {
-				ansiCode = false
-			}
-		} else {
-			if r < 128 {
-				// if ascii, then use width of 1. this saves some time
-				totalLen++
-			} else {
-				totalLen += runewidth.RuneWidth(r)
-			}
+// IndentSprite adds n spaces to the start of each line of a sprite
+func IndentSprite(sprite []byte, n int) []byte {
+	if n <= 0 {
+		return sprite
+	}
+	indent := strings.Repeat(" ", n)
+	lines := strings.SplitAfter(string(sprite), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
 		}
 	}
-	return totalLen
+	return []byte(strings.Join(lines, ""))
 }
 
-// Prints a pokemon with its name & category information.
-func printPokemon(args Args, index int, names []string, categoryKeys []string, GOBCowData embed.FS) {
-	d, _ := GOBCowData.ReadFile(pokedex.EntryFpath("build/assets/cows", index))
+// Reads & decompresses the cowfile data of a pokemon
+func readSprite(index int, GOBCowData embed.FS) []byte {
+	d, _ := GOBCowData.ReadFile(pokedex.EntryFpath(SpriteRoot, index))
+	return pokedex.Decompress(d)
+}
 
-	width := nameLength(names)
-	namesFmt := make([]string, 0)
-	for _, name := range names {
-		namesFmt = append(namesFmt, textStyleBold.Sprint(name))
+// RenderInfoBox returns the info box that is printed with a pokemon, from the InfoFormat
+// - Each line of the format is rendered as markup (see RenderMarkup), e.g. **{name}** is bold
+// - If the info border is drawn, then it fits around the widest line
+// - On a light background, the text colours are adjusted so that they're readable (see
+// ReadableOnLight), and the text in the terminal's own colour (e.g. the bold & italic of the default
+// info box) is printed in a dark colour (see DarkenDefaultText)
+func RenderInfoBox(args Args, info PokemonInfo) string {
+	format := args.InfoFormat
+	if format == "" {
+		format = DefaultInfoFormat(args.Languages, args.JapaneseName, !args.NoCategoryInfo)
 	}
-	// count name separators
-	width += (len(names) - 1) * 3
-	width += 2     // for the arrow
-	width += 2 + 2 // for the end box characters
-
-	infoLine := ""
+	text, err := ExpandInfoFormat(format, infoValues(args, info))
+	pokedex.Check(err)
 
-	if args.NoCategoryInfo {
-		infoLine = fmt.Sprintf(
-			"%s %s",
-			args.BoxChars.RightArrow, strings.Join(namesFmt, fmt.Sprintf(" %s ", args.BoxChars.Separator)),
-		)
-	} else {
-		infoLine = fmt.Sprintf(
-			"%s %s %s %s",
-			args.BoxChars.RightArrow,
-			strings.Join(namesFmt, fmt.Sprintf(" %s ", args.BoxChars.Separator)),
-			args.BoxChars.Separator,
-			textStyleItalic.Sprint(strings.Join(categoryKeys, args.BoxChars.CategorySeparator)),
-		)
-		for _, category := range categoryKeys {
-			width += len(category)
+	lines := strings.Split(text, "\n")
+	width := 0
+	for i, line := range lines {
+		lines[i] = RenderMarkup(line)
+		if args.Background == "light" {
+			lines[i] = DarkenDefaultText(ReadableOnLight(lines[i]))
+		}
+		if lineWidth := UnicodeStringLength(lines[i]); lineWidth > width {
+			width = lineWidth
 		}
-		width += len(categoryKeys) - 1 + 1 + 2 // lol why did I do this
 	}
 
-	if args.DrawInfoBorder {
-		topBorder := fmt.Sprintf(
-			"%s%s%s",
-			args.BoxChars.TopLeftCorner, strings.Repeat(args.BoxChars.HorizontalEdge, width-2), args.BoxChars.TopRightCorner,
-		)
-		bottomBorder := fmt.Sprintf(
-			"%s%s%s",
-			args.BoxChars.BottomLeftCorner, strings.Repeat(args.BoxChars.HorizontalEdge, width-2), args.BoxChars.BottomRightCorner,
-		)
-		infoLine = fmt.Sprintf(
-			"%s\n%s %s %s\n%s\n",
-			topBorder, args.BoxChars.VerticalEdge, infoLine, args.BoxChars.VerticalEdge, bottomBorder,
+	if !args.DrawInfoBorder {
+		return strings.Join(lines, "\n") + "\n"
+	}
+	var b strings.Builder
+	edge := strings.Repeat(args.BoxChars.HorizontalEdge, width+2)
+	fmt.Fprintf(&b, "%s%s%s\n", args.BoxChars.TopLeftCorner, edge, args.BoxChars.TopRightCorner)
+	for _, line := range lines {
+		fmt.Fprintf(
+			&b, "%s %s%s %s\n",
+			args.BoxChars.VerticalEdge, line, strings.Repeat(" ", width-UnicodeStringLength(line)), args.BoxChars.VerticalEdge,
 		)
-	} else {
-		infoLine = fmt.Sprintf("%s\n", infoLine)
 	}
-	fmt.Printf("%s%s", pokedex.Decompress(d), infoLine)
+	fmt.Fprintf(&b, "%s%s%s\n", args.BoxChars.BottomLeftCorner, edge, args.BoxChars.BottomRightCorner)
+	return b.String()
 }
@@ -0,0 +1,163 @@
+package pokesay
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	SceneGap       int = 2  // the number of spaces between each pokemon in a scene
+	MinBubbleWidth int = 10 // the narrowest speech bubble that is drawn with --width auto, or in a scene
+)
+
+// ScenePokemon is a chosen pokemon that appears in a scene, along with the info to print in its
+// info box
+type ScenePokemon struct {
+	EntryIndex int
+	HeadColumn int // the column of the top of the pokemon's head, in the sprite
+	Width      int // the width of the sprite, in columns
+	Info       PokemonInfo
+}
+
+// PrintScene prints multiple pokemon side by side
+// - If the split delimiter is empty, then the text from STDIN is printed in a single shared speech
+// bubble above all of the pokemon
+// - Otherwise, the text from STDIN is split into messages, and each pokemon "speaks" a message in
+// its own speech bubble. If there are more messages than pokemon, then the pokemon take turns,
+// and the scene is printed over multiple rows
+//
+// An error is returned if the text can't be read.
+func PrintScene(args Args, pokemon []ScenePokemon, cows embed.FS) error {
+	if args.SplitDelimiter == "" {
+		// the shared speech bubble points at the first pokemon, which is aligned within its own block
+		args.HeadColumn = SpriteOffset(sceneBlockArgs(args, len(pokemon)), pokemon[0].Width) + pokemon[0].HeadColumn
+		if err := printSpeechBubble(args.output(), args.BoxChars, args.input(), args); err != nil {
+			return err
+		}
+		printScene(args.output(), args, pokemon, make([]string, len(pokemon)), cows)
+		return nil
+	}
+	input, err := io.ReadAll(args.input())
+	if err != nil {
+		return err
+	}
+	messages := SplitMessages(string(input), args.SplitDelimiter)
+
+	for start := 0; start == 0 || start < len(messages); start += len(pokemon) {
+		row := make([]string, len(pokemon))
+		for i := range row {
+			if start+i < len(messages) {
+				row[i] = messages[start+i]
+			}
+		}
+		printScene(args.output(), args, pokemon, row, cows)
+	}
+	return nil
+}
+
+// printScene prints a single row of pokemon side by side, each pokemon with a non-empty message
+// is drawn with its own speech bubble
+func printScene(w io.Writer, args Args, pokemon []ScenePokemon, messages []string, cows embed.FS) {
+	blockArgs := sceneBlockArgs(args, len(pokemon))
+
+	blocks := make([][]string, 0, len(pokemon))
+	for i, p := range pokemon {
+		var buf bytes.Buffer
+		blockArgs.SpriteWidth = p.Width
+		if messages[i] != "" {
+			blockArgs.HeadColumn = SpriteOffset(blockArgs, p.Width) + p.HeadColumn
+			// the messages have already been read, so they can't fail
+			printSpeechBubble(&buf, args.BoxChars, strings.NewReader(messages[i]), blockArgs)
+		}
+		printPokemon(&buf, blockArgs, p.EntryIndex, p.Info, cows)
+		blocks = append(blocks, strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"))
+	}
+	for _, line := range JoinBlocks(blocks, SceneGap) {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// sceneBlockArgs returns the args for printing each of n pokemon in a scene, with a narrower speech
+// bubble. Each pokemon is aligned within its own block, rather than the terminal
+func sceneBlockArgs(args Args, n int) Args {
+	args.Width = sceneBubbleWidth(args.Width, n)
+	args.SpriteAlignTo = "bubble"
+	return args
+}
+
+// sceneBubbleWidth divides the speech bubble width between n pokemon, so that the scene is
+// roughly as wide as a single speech bubble would be
+func sceneBubbleWidth(width int, n int) int {
+	// each bubble has 4 extra columns for the edges & padding, e.g. "| " & " |"
+	bubbleWidth := (width+4-SceneGap*(n-1))/n - 4
+	if bubbleWidth < MinBubbleWidth {
+		return MinBubbleWidth
+	}
+	return bubbleWidth
+}
+
+// SplitMessages splits text into separate messages
+// - If the delimiter is "lines", then each non-empty line is a separate message
+// - Otherwise, the messages are separated by lines that only contain the delimiter, e.g. "---"
+func SplitMessages(text string, delimiter string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	messages := make([]string, 0)
+	if delimiter == "lines" {
+		for _, line := range lines {
+			if strings.TrimSpace(line) != "" {
+				messages = append(messages, line)
+			}
+		}
+		return messages
+	}
+
+	current := make([]string, 0)
+	for _, line := range append(lines, delimiter) {
+		if strings.TrimSpace(line) != delimiter {
+			current = append(current, line)
+			continue
+		}
+		if message := strings.Trim(strings.Join(current, "\n"), "\n"); message != "" {
+			messages = append(messages, message)
+		}
+		current = make([]string, 0)
+	}
+	return messages
+}
+
+// JoinBlocks joins blocks of lines horizontally, separated by a gap of spaces
+// - Each block is padded to the width of its widest line, ignoring any ANSI escape codes
+// - The colours are reset at the end of every block line, so that they don't bleed into the next block
+// - Blocks are aligned to the bottom, so that all of the pokemon info boxes line up
+func JoinBlocks(blocks [][]string, gap int) []string {
+	height := 0
+	widths := make([]int, len(blocks))
+	for i, block := range blocks {
+		if len(block) > height {
+			height = len(block)
+		}
+		for _, line := range block {
+			if lineWidth := UnicodeStringLength(line); lineWidth > widths[i] {
+				widths[i] = lineWidth
+			}
+		}
+	}
+
+	joined := make([]string, height)
+	for row := 0; row < height; row++ {
+		parts := make([]string, len(blocks))
+		for i, block := range blocks {
+			line := ""
+			if offset := row - (height - len(block)); offset >= 0 {
+				line = block[offset]
+			}
+			parts[i] = line + resetColourANSI + strings.Repeat(" ", widths[i]-UnicodeStringLength(line))
+		}
+		joined[row] = strings.TrimRight(strings.Join(parts, strings.Repeat(" ", gap)), " ")
+	}
+	return joined
+}
@@ -0,0 +1,137 @@
+package pokesay
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+var (
+	MaxRequestBytes int64 = 1 << 20 // the max size of the text in the body of a POST request (1MiB)
+	MaxQueryWidth   int   = 500     // the max speech bubble width that can be requested
+)
+
+// Chooser chooses a pokemon by name (or any pokemon, if the name is empty), and the category in the
+// args (if any)
+type Chooser func(args Args, name string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error)
+
+// ServeHandler returns a HTTP handler that prints a pokemon for each request, with the args from
+// the query parameters (see QueryArgs)
+// - The text is the "text" query parameter, or the body of a POST request (up to MaxRequestBytes)
+// - Invalid query parameters & text that can't be read are a 400, a pokemon that can't be found is
+// a 404, and text that is too large is a 413
+func ServeHandler(index []pokedex.CategoryDimension, choose Chooser, cows embed.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args, err := QueryArgs(r.URL.Query())
+		if err == nil {
+			args.Category, err = ParseCategory(args.Category, index)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		text := io.Reader(strings.NewReader(r.URL.Query().Get("text")))
+		if r.Method == http.MethodPost {
+			text = http.MaxBytesReader(w, r.Body, MaxRequestBytes)
+		}
+
+		name := ""
+		if len(args.NameTokens) > 0 {
+			name = args.NameTokens[0]
+		}
+		metadata, final, err := choose(args, name)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		args.SpriteWidth = final.Width
+		args.HeadColumn = SpriteOffset(args, final.Width) + final.HeadColumn
+
+		// the output is buffered, so that nothing is sent if the text can't be read
+		var output bytes.Buffer
+		if err := Fprint(&output, text, args, final.EntryIndex, NewPokemonInfo(metadata, final, args.InfoDimensions), cows); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(output.Bytes())
+	})
+}
+
+// QueryArgs converts the query parameters of a HTTP request into Args, for serving pokemon over HTTP
+// - name & category choose the pokemon, in the same way as --name & --category
+// - width is the max speech bubble width (defaults to 80), up to MaxQueryWidth
+// - title & footer are printed in the speech bubble border, in the same way as --title & --footer
+// - japanese-name, info-border & unicode-borders are true if they are given without a value, or
+// with a true value (e.g. "1" or "true")
+func QueryArgs(query url.Values) (Args, error) {
+	args := Args{
+		Width:      80,
+		DrawBubble: true,
+		TabSpaces:  "    ",
+		Indent:     DefaultIndent,
+		Category:   query.Get("category"),
+		Title:      query.Get("title"),
+		Footer:     query.Get("footer"),
+		BoxChars:   AsciiBoxChars,
+	}
+	if name := query.Get("name"); name != "" {
+		args.NameTokens = []string{name}
+	}
+	if width := query.Get("width"); width != "" {
+		n, err := strconv.Atoi(width)
+		if err != nil || n < 1 || n > MaxQueryWidth {
+			return args, fmt.Errorf("invalid width '%s', must be a number from 1 to %d", width, MaxQueryWidth)
+		}
+		args.Width = n
+	}
+
+	for key, value := range map[string]*bool{
+		"japanese-name": &args.JapaneseName,
+		"info-border":   &args.DrawInfoBorder,
+	} {
+		b, err := queryBool(query, key)
+		if err != nil {
+			return args, err
+		}
+		*value = b
+	}
+	unicodeBorders, err := queryBool(query, "unicode-borders")
+	if err != nil {
+		return args, err
+	}
+	args.BoxChars = DetermineBoxChars(unicodeBorders)
+
+	return args, nil
+}
+
+func queryBool(query url.Values, key string) (bool, error) {
+	if !query.Has(key) {
+		return false, nil
+	}
+	if query.Get(key) == "" {
+		return true, nil
+	}
+	b, err := strconv.ParseBool(query.Get(key))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s '%s', must be true or false", key, query.Get(key))
+	}
+	return b, nil
+}
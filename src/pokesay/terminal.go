@@ -0,0 +1,170 @@
+package pokesay
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+var (
+	DefaultTerminalWidth int    = 80
+	DefaultPager         string = "less" // the pager that is used if $PAGER isn't set
+	// The backgrounds that the colours can be adjusted for, auto asks the terminal for its background
+	Backgrounds            []string      = []string{"dark", "light", "auto"}
+	DefaultBackground      string        = "dark"
+	BackgroundQueryTimeout time.Duration = 100 * time.Millisecond // how long to wait for the terminal to reply
+)
+
+// TerminalWidth returns the width of the terminal, in columns
+// - It first queries the size of the terminal attached to STDOUT (or STDERR, if STDOUT is redirected)
+// - If neither is a terminal, it falls back to the $COLUMNS environment variable
+// - Finally, it falls back to DefaultTerminalWidth
+func TerminalWidth() int {
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		if width, _, err := term.GetSize(int(f.Fd())); err == nil && width > 0 {
+			return width
+		}
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return DefaultTerminalWidth
+}
+
+// Page writes output to STDOUT, through a pager if STDOUT is a terminal that the output is too tall for
+// - The pager is $PAGER, or DefaultPager if it isn't set. If $LESS isn't set, then it's set to
+// "FRX", so that less keeps the colours, and exits if the output fits on one screen
+// - If the pager can't be run, then the output is written to STDOUT as-is
+func Page(output []byte) error {
+	_, height, err := term.GetSize(int(os.Stdout.Fd()))
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{DefaultPager}
+	}
+	if err != nil || bytes.Count(output, []byte("\n")) < height {
+		_, err = os.Stdout.Write(output)
+		return err
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(output), os.Stdout, os.Stderr
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	if err := cmd.Start(); err != nil {
+		_, err = os.Stdout.Write(output)
+		return err
+	}
+	return cmd.Wait()
+}
+
+// DetectBackground returns the background of the terminal, "light" or "dark", by asking the terminal
+// for its background colour (with an OSC 11 query)
+// - A device attributes query (DA1) is sent after it. Every terminal replies to DA1, and the replies
+// are in order, so once the DA1 reply is read, there's nothing left to leak into the shell's input.
+// This also means that terminals that don't support OSC 11 don't have to wait for the timeout
+// - If STDOUT isn't a terminal, or the terminal doesn't reply within the BackgroundQueryTimeout, then
+// it falls back to DefaultBackground. It waits for the timeout once more to drain a late reply, a
+// reply that is even later than that can still be read by the shell
+func DetectBackground() string {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return DefaultBackground
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return DefaultBackground
+	}
+	defer tty.Close()
+
+	// calling tty.Fd() would put the tty into blocking mode, and then the read deadline wouldn't work
+	conn, err := tty.SyscallConn()
+	if err != nil {
+		return DefaultBackground
+	}
+	var state *term.State
+	conn.Control(func(fd uintptr) { state, err = term.MakeRaw(int(fd)) })
+	if err != nil {
+		return DefaultBackground
+	}
+	defer conn.Control(func(fd uintptr) { term.Restore(int(fd), state) })
+
+	// only ask if the reply can be timed out
+	if err := tty.SetReadDeadline(time.Now().Add(BackgroundQueryTimeout)); err != nil {
+		return DefaultBackground
+	}
+	if _, err := tty.WriteString("\033]11;?\033\\\033[c"); err != nil {
+		return DefaultBackground
+	}
+	reply, ok := readTerminalReply(tty, BackgroundQueryTimeout)
+	if !ok {
+		// drain a late reply, so that it's read here rather than by the shell
+		late, _ := readTerminalReply(tty, BackgroundQueryTimeout)
+		reply = append(reply, late...)
+	}
+	colour, ok := ParseBackgroundColour(string(reply))
+	if !ok {
+		return DefaultBackground
+	}
+	return BackgroundOf(colour)
+}
+
+// the reply to a device attributes query, e.g. "\033[?62;22c"
+var deviceAttributesReply *regexp.Regexp = regexp.MustCompile(`\x1b\[\?[0-9;]*c`)
+
+// readTerminalReply reads from the tty until the reply to a device attributes query has been read,
+// or the timeout passes (then ok is false)
+func readTerminalReply(tty *os.File, timeout time.Duration) (reply []byte, ok bool) {
+	if err := tty.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, false
+	}
+	reply, buf := make([]byte, 0, 64), make([]byte, 64)
+	for !deviceAttributesReply.Match(reply) {
+		n, err := tty.Read(buf)
+		reply = append(reply, buf[:n]...)
+		if err != nil {
+			return reply, false
+		}
+	}
+	return reply, true
+}
+
+// ParseBackgroundColour parses the colour from a terminal's reply to an OSC 11 query,
+// e.g. "\033]11;rgb:ffff/ffff/dddd\033\\", which can be followed by other replies
+// - Each channel has 1 to 4 hex digits, which are scaled to 0-255
+func ParseBackgroundColour(reply string) (RGB, bool) {
+	_, spec, ok := strings.Cut(reply, "rgb:")
+	if !ok {
+		return RGB{}, false
+	}
+	// the reply ends with either BEL or ST (ESC \)
+	if end := strings.IndexAny(spec, "\a\033"); end >= 0 {
+		spec = spec[:end]
+	}
+	channels := strings.Split(spec, "/")
+	if len(channels) != 3 {
+		return RGB{}, false
+	}
+	var colour RGB
+	for i, channel := range channels {
+		v, err := strconv.ParseUint(channel, 16, 16)
+		if err != nil || len(channel) == 0 || len(channel) > 4 {
+			return RGB{}, false
+		}
+		colour[i] = float64(v) / float64(uint64(1)<<(4*len(channel))-1) * 255
+	}
+	return colour, true
+}
+
+// BackgroundOf returns whether a background colour is "light" or "dark", from its luma
+func BackgroundOf(colour RGB) string {
+	if 0.299*colour[0]+0.587*colour[1]+0.114*colour[2] > 127.5 {
+		return "light"
+	}
+	return "dark"
+}
@@ -0,0 +1,372 @@
+package pokesay
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/uniseg"
+	"github.com/tmck-code/pokesay/src/pokedex"
+)
+
+var (
+	// The modes that text can be wrapped with
+	// - word: break lines between words, and hyphenate words that are wider than a line. Other long
+	// tokens (e.g. URLs & hashes) are broken without a hyphen, so that no characters are added. A
+	// token is everything between spaces, so a URL isn't hyphenated even where only letters are broken
+	// - char: break lines at any character, e.g. for long URLs & hashes
+	// - justify: break lines between words, and spread the spaces evenly so that each line fills the width
+	// - none: don't wrap lines
+	WrapModes []string = []string{"word", "char", "justify", "none"}
+	// The alignments of the text in a speech bubble
+	Alignments []string = []string{"left", "center", "right"}
+)
+
+// ansiEscape is a terminal escape sequence in a line of text, at a byte offset of the visible text
+type ansiEscape struct {
+	Pos int
+	Seq string
+}
+
+// lineSpan is a wrapped line, as the byte offsets of the visible text where it starts & ends
+type lineSpan struct {
+	Start  int
+	End    int
+	Hyphen bool // whether a word was broken at the end of the line
+}
+
+// WrapText wraps a line of text so that no line is wider than the width (in terminal columns),
+// using one of the WrapModes (an empty mode is the same as "word")
+// - The width is measured like UnicodeStringLength, so escape sequences are zero-width, and
+// wide characters (e.g. CJK) are 2 columns wide
+// - In word & justify modes, lines are only broken where the Unicode line breaking algorithm
+// (UAX #14) allows, e.g. after spaces, or between CJK characters
+// - An indented line keeps its indent on each of the wrapped lines (a hanging indent)
+// - The colours that are active at the end of a line are re-applied at the start of the next line
+func WrapText(text string, width int, mode string) []string {
+	plain, escapes := splitEscapes(text)
+
+	indent := plain[:len(plain)-len(strings.TrimLeft(plain, " "))]
+	if len(indent)*2 > width {
+		indent = ""
+	}
+	var spans []lineSpan
+	if mode == "char" {
+		spans = breakChars(plain, width, len(indent))
+	} else {
+		spans = breakWords(plain, width, len(indent))
+	}
+
+	// rebuild each line, with the escape codes that are positioned in it
+	lines := make([]string, len(spans))
+	active, e := make([]sgrAttribute, 0), 0
+	for i, span := range spans {
+		var b strings.Builder
+		if i > 0 {
+			b.WriteString(indent)
+		}
+		// the colours at the very start of a wrapped line are merged with the active colours, so
+		// that replaced colours aren't re-applied
+		for ; i > 0 && e < len(escapes) && escapes[e].Pos <= span.Start && isSGR(escapes[e].Seq); e++ {
+			active = updateSGRState(active, escapes[e].Seq)
+		}
+		for _, attribute := range active {
+			b.WriteString(attribute.Seq)
+		}
+		last := span.Start
+		for ; e < len(escapes) && (i == len(spans)-1 || escapes[e].Pos < spans[i+1].Start); e++ {
+			at := escapes[e].Pos
+			if at > span.End {
+				at = span.End
+			}
+			if at > last {
+				b.WriteString(plain[last:at])
+				last = at
+			}
+			b.WriteString(escapes[e].Seq)
+			active = updateSGRState(active, escapes[e].Seq)
+		}
+		if span.End > last {
+			b.WriteString(plain[last:span.End])
+		}
+		if span.Hyphen {
+			b.WriteString("-")
+		}
+		lines[i] = b.String()
+
+		if mode == "justify" && i < len(spans)-1 {
+			lines[i] = justifyLine(lines[i], width, len(indent))
+		}
+	}
+	return lines
+}
+
+// breakWords breaks text into lines between words, greedily fitting as many words on each line as
+// possible. A word that is wider than a whole line is hyphenated if it only has letters, otherwise
+// it's broken at the end of the line, like breakChars
+// - The spaces at the start of the text (i.e. the indent) aren't a word, so a long word after them
+// is broken on the first line, rather than leaving the first line empty
+func breakWords(plain string, width int, indent int) []lineSpan {
+	spans := make([]lineSpan, 0)
+	start, lineWidth, pos, state, available := 0, 0, 0, -1, width
+	words := false // whether the line has any words, rather than just the indent
+	for rest := plain; len(rest) > 0; {
+		var segment string
+		segment, rest, _, state = uniseg.FirstLineSegmentInString(rest, state)
+		segmentWidth := UnicodeStringLength(strings.TrimRight(segment, " "))
+
+		if words && lineWidth+segmentWidth > available {
+			spans = append(spans, lineSpan{start, len(strings.TrimRight(plain[:pos], " ")), false})
+			start, lineWidth, available, words = pos, 0, width-indent, false
+		}
+		// break a word that doesn't fit on a line by itself
+		// punctuation around a word (e.g. a comma after it) doesn't stop it from being hyphenated
+		hyphenate := isAlphabetic(strings.TrimFunc(tokenAt(plain, pos), unicode.IsPunct))
+		for !words && lineWidth+segmentWidth > available {
+			room := available - lineWidth
+			if hyphenate {
+				room--
+			}
+			if room < 1 && lineWidth > 0 {
+				// there's no room for the word after the indent, so the indent is dropped
+				start, lineWidth = pos, 0
+				continue
+			}
+			n := prefixLength(segment, room)
+			spans = append(spans, lineSpan{start, pos + n, hyphenate})
+			segment, pos = segment[n:], pos+n
+			start, lineWidth, available = pos, 0, width-indent
+			segmentWidth = UnicodeStringLength(strings.TrimRight(segment, " "))
+		}
+		lineWidth += UnicodeStringLength(segment)
+		words = words || segmentWidth > 0
+		pos += len(segment)
+	}
+	if len(spans) == 0 {
+		// don't trim the trailing spaces if the line isn't wrapped
+		return []lineSpan{{0, len(plain), false}}
+	}
+	return append(spans, lineSpan{start, len(strings.TrimRight(plain, " ")), false})
+}
+
+// breakChars breaks text into lines at any grapheme cluster, filling each line. Spaces at the start
+// of a wrapped line are skipped
+func breakChars(plain string, width int, indent int) []lineSpan {
+	spans := make([]lineSpan, 0)
+	start, lineWidth, pos, state, available := 0, 0, 0, -1, width
+	for rest := plain; len(rest) > 0; {
+		var cluster string
+		var clusterWidth int
+		cluster, rest, clusterWidth, state = uniseg.FirstGraphemeClusterInString(rest, state)
+
+		if lineWidth > 0 && lineWidth+clusterWidth > available {
+			spans = append(spans, lineSpan{start, len(strings.TrimRight(plain[:pos], " ")), false})
+			start, lineWidth, available = pos, 0, width-indent
+		}
+		pos += len(cluster)
+		if lineWidth == 0 && cluster == " " && len(spans) > 0 {
+			start = pos
+			continue
+		}
+		lineWidth += clusterWidth
+	}
+	if len(spans) == 0 {
+		return []lineSpan{{0, len(plain), false}}
+	}
+	return append(spans, lineSpan{start, len(strings.TrimRight(plain, " ")), false})
+}
+
+// tokenAt returns the token (i.e. the text between spaces) around a byte offset of the text, e.g.
+// the whole URL for the last part of "see https://example.com/abc"
+func tokenAt(text string, pos int) string {
+	start := strings.LastIndexByte(text[:pos], ' ') + 1
+	end := strings.IndexByte(text[pos:], ' ')
+	if end < 0 {
+		return text[start:]
+	}
+	return text[start : pos+end]
+}
+
+// isAlphabetic returns true if a word only has letters (and combining marks), so that it can be
+// hyphenated
+func isAlphabetic(word string) bool {
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) {
+			return false
+		}
+	}
+	return word != ""
+}
+
+// prefixLength returns the length in bytes of the longest prefix of s that fits within the width,
+// without splitting a grapheme cluster. At least one grapheme cluster is always included
+func prefixLength(s string, width int) int {
+	n, totalWidth, state := 0, 0, -1
+	for rest := s; len(rest) > 0; {
+		var cluster string
+		var clusterWidth int
+		cluster, rest, clusterWidth, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if n > 0 && totalWidth+clusterWidth > width {
+			break
+		}
+		n, totalWidth = n+len(cluster), totalWidth+clusterWidth
+	}
+	return n
+}
+
+// justifyLine adds spaces between the words of a line, spreading them as evenly as possible, so
+// that the line is as wide as the width. The indent at the start of the line is not changed
+func justifyLine(line string, width int, indent int) string {
+	extra := width - UnicodeStringLength(line)
+	if extra <= 0 {
+		return line
+	}
+	// find the byte offsets of the gaps between words, i.e. the spaces after the indent that
+	// follow a visible character
+	gaps, visible, prevSpace := make([]int, 0), 0, true
+	for i := 0; i < len(line); {
+		if n := pokedex.EscapeLength(line[i:]); n > 0 {
+			i += n
+			continue
+		}
+		if line[i] == ' ' && visible >= indent && !prevSpace {
+			gaps = append(gaps, i)
+		}
+		prevSpace = line[i] == ' '
+		if line[i] < 0x80 || line[i] >= 0xc0 {
+			visible++
+		}
+		i++
+	}
+	if len(gaps) == 0 {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for g, gap := range gaps {
+		b.WriteString(line[last:gap])
+		// the first gaps get any remaining spaces
+		n := extra / len(gaps)
+		if g < extra%len(gaps) {
+			n++
+		}
+		b.WriteString(strings.Repeat(" ", n))
+		last = gap
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// splitEscapes separates the escape sequences from the visible text of a line
+func splitEscapes(text string) (string, []ansiEscape) {
+	var plain strings.Builder
+	escapes := make([]ansiEscape, 0)
+	for i := 0; i < len(text); {
+		if n := pokedex.EscapeLength(text[i:]); n > 0 {
+			escapes = append(escapes, ansiEscape{plain.Len(), text[i : i+n]})
+			i += n
+			continue
+		}
+		plain.WriteByte(text[i])
+		i++
+	}
+	return plain.String(), escapes
+}
+
+// sgrAttribute is an active SGR (colour/style) attribute, e.g. the foreground colour, and the escape
+// code that sets it
+type sgrAttribute struct {
+	Group string
+	Seq   string
+}
+
+// isSGR returns whether an escape code is an SGR (colour/style) code, e.g. "\033[31m"
+func isSGR(seq string) bool {
+	return strings.HasPrefix(seq, "\033[") && strings.HasSuffix(seq, "m")
+}
+
+// sgrGroup returns the group of attributes that an SGR parameter sets, e.g. "fg" for "31" or
+// "38;5;196", and whether the parameter turns the group off (e.g. "39" for the default foreground)
+func sgrGroup(code int) (string, bool) {
+	switch {
+	case code == 1 || code == 2:
+		return "intensity", false
+	case code == 22:
+		return "intensity", true
+	case code == 4 || code == 21:
+		return "underline", false
+	case code == 24:
+		return "underline", true
+	case code == 5 || code == 6:
+		return "blink", false
+	case code == 25:
+		return "blink", true
+	case code == 3 || (code >= 7 && code <= 9):
+		return strconv.Itoa(code), false
+	case code == 23 || (code >= 27 && code <= 29):
+		return strconv.Itoa(code - 20), true
+	case (code >= 30 && code <= 38) || (code >= 90 && code <= 97):
+		return "fg", false
+	case code == 39:
+		return "fg", true
+	case (code >= 40 && code <= 48) || (code >= 100 && code <= 107):
+		return "bg", false
+	case code == 49:
+		return "bg", true
+	case code == 58:
+		return "underline-colour", false
+	case code == 59:
+		return "underline-colour", true
+	}
+	return strconv.Itoa(code), false
+}
+
+// updateSGRState returns the SGR attributes that are active after an escape code
+// - Each attribute replaces any active attribute in the same group, e.g. "\033[32m" replaces "\033[31m"
+// - An attribute that turns a group off (e.g. "\033[22m" for bold) removes it
+// - A reset (e.g. "\033[0m") clears all of the active attributes
+//
+// Other escape codes (e.g. hyperlinks) don't change the active attributes.
+func updateSGRState(active []sgrAttribute, seq string) []sgrAttribute {
+	if !isSGR(seq) {
+		return active
+	}
+	params := strings.Split(seq[2:len(seq)-1], ";")
+	for i := 0; i < len(params); i++ {
+		// colon-separated sub-parameters (e.g. "38:5:196") are a part of the parameter
+		code, err := strconv.Atoi(strings.SplitN(params[i], ":", 2)[0])
+		if params[i] == "" || code == 0 {
+			active = make([]sgrAttribute, 0)
+			continue
+		} else if err != nil {
+			continue
+		}
+		param := params[i]
+		// extended colours take more parameters, e.g. "38;5;196" or "38;2;255;0;0"
+		if (code == 38 || code == 48 || code == 58) && !strings.Contains(param, ":") && i+1 < len(params) {
+			n := 2
+			if params[i+1] == "2" {
+				n = 4
+			}
+			if i+n >= len(params) {
+				n = len(params) - 1 - i
+			}
+			param = strings.Join(params[i:i+n+1], ";")
+			i += n
+		}
+
+		group, off := sgrGroup(code)
+		updated := make([]sgrAttribute, 0, len(active)+1)
+		for _, attribute := range active {
+			if attribute.Group != group {
+				updated = append(updated, attribute)
+			}
+		}
+		if !off {
+			updated = append(updated, sgrAttribute{group, "\033[" + param + "m"})
+		}
+		active = updated
+	}
+	return active
+}
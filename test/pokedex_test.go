@@ -2,8 +2,12 @@ package test
 
 import (
 	"embed"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/tmck-code/pokesay/src/pokedex"
 )
@@ -19,14 +23,38 @@ func TestReadNames(test *testing.T) {
 	result := pokedex.ReadNames("./data/pokemon.json")
 
 	expected := map[string]pokedex.PokemonName{
-		"bulbasaur": {English: "Bulbasaur", Japanese: "フシギダネ", JapanesePhonetic: "fushigidane", Slug: "bulbasaur"},
-		"ivysaur":   {English: "Ivysaur", Japanese: "フシギソウ", JapanesePhonetic: "fushigisou", Slug: "ivysaur"},
-		"venusaur":  {English: "Venusaur", Japanese: "フシギバナ", JapanesePhonetic: "fushigibana", Slug: "venusaur"},
+		"bulbasaur": {
+			English: "Bulbasaur", Japanese: "フシギダネ", JapanesePhonetic: "fushigidane", Slug: "bulbasaur", Dex: "001",
+			Names: map[string]string{"en": "Bulbasaur", "ja": "フシギダネ", "ja-ro": "Fushigidane", "zh": "妙蛙种子"},
+		},
+		"ivysaur": {
+			English: "Ivysaur", Japanese: "フシギソウ", JapanesePhonetic: "fushigisou", Slug: "ivysaur", Dex: "002",
+			Names: map[string]string{"en": "Ivysaur", "ja": "フシギソウ", "ja-ro": "Fushigisou", "zh": "妙蛙草"},
+		},
+		"venusaur": {
+			English: "Venusaur", Japanese: "フシギバナ", JapanesePhonetic: "fushigibana", Slug: "venusaur", Dex: "003",
+			Names: map[string]string{"en": "Venusaur", "ja": "フシギバナ", "ja-ro": "Fushigibana", "zh": "妙蛙花"},
+		},
 	}
 
 	Assert(expected, result, test)
 }
 
+func TestNewPokemonName(test *testing.T) {
+	entry := pokedex.DataEntry{
+		Idx:  "025",
+		Name: map[string]string{"eng": "Pikachu", "jpn": "ピカチュウ", "chs": "皮卡丘", "fra": "Pikachu"},
+	}
+	entry.Slug.Eng, entry.Slug.Jpn = "pikachu", "pikachu"
+
+	// the name in a language without a language code (fra) is dropped
+	result := pokedex.NewPokemonName(entry)
+	Assert(map[string]string{"en": "Pikachu", "ja": "ピカチュウ", "zh": "皮卡丘"}, result.Names, test)
+	for language := range result.Names {
+		Assert(true, pokedex.ContainsString(pokedex.Languages, language), test)
+	}
+}
+
 func TestReadEntry(test *testing.T) {
 	result := pokedex.ReadPokemonCow(GOBCowData, "data/cows/1.cow")
 
@@ -53,3 +81,136 @@ func TestReadMetadataFromEmbedded(test *testing.T) {
 
 	Assert(expected, result, test)
 }
+
+func TestCowfileDimensions(test *testing.T) {
+	data, err := os.ReadFile("data/cows/egg.cow")
+	pokedex.Check(err)
+
+	width, height := pokedex.CowfileDimensions(data)
+
+	Assert(17, width, test)
+	Assert(8, height, test)
+}
+
+func TestSpriteHeadColumn(test *testing.T) {
+	data, err := os.ReadFile("data/cows/egg.cow")
+	pokedex.Check(err)
+
+	Assert(10, pokedex.SpriteHeadColumn(data), test)
+
+	// blank lines & spaces without a background colour are skipped, extended foreground colours
+	// aren't mistaken for a background colour
+	Assert(7, pokedex.SpriteHeadColumn([]byte("\n  \033[38;5;40m    \033[48;5;16m  \033[49m▄▄\n▄▄▄▄▄▄▄▄▄▄▄▄")), test)
+	Assert(0, pokedex.SpriteHeadColumn([]byte("   \n")), test)
+}
+
+func TestTrimCowfile(test *testing.T) {
+	cowfile := "\n      \n    \033[49m  \033[38;5;16m▄▄\n      ▄\033[48;5;16m  \033[49m\n   \033[49m  \n\033[39m\n"
+
+	Assert(
+		"\033[49m  \033[38;5;16m▄▄\n  ▄\033[48;5;16m  \033[49m\033[39m\n",
+		string(pokedex.TrimCowfile([]byte(cowfile))),
+		test,
+	)
+	// the padding that was baked into older cowfiles is removed
+	data, err := os.ReadFile("data/cows/egg.cow")
+	pokedex.Check(err)
+	trimmed := pokedex.TrimCowfile(data)
+	width, height := pokedex.CowfileDimensions(trimmed)
+	Assert(13, width, test)
+	Assert(8, height, test)
+	Assert(6, pokedex.SpriteHeadColumn(trimmed), test)
+}
+
+func TestCreateDimensionIndex(test *testing.T) {
+	metadata := []pokedex.PokemonMetadata{
+		{Entries: []pokedex.PokemonEntryMapping{
+			{Dimensions: map[string]string{"size": "big", "gen": "gen8", "variant": "shiny"}},
+			{Dimensions: map[string]string{"size": "small", "gen": "gen7x", "variant": "regular", "form": "female"}},
+		}},
+	}
+
+	expected := []pokedex.CategoryDimension{
+		{Name: "size", Values: []string{"small", "big"}},
+		{Name: "gen", Values: []string{"gen7x", "gen8"}},
+		{Name: "variant", Values: []string{"regular", "shiny"}},
+		{Name: "form", Values: []string{"female"}},
+	}
+	Assert(expected, pokedex.CreateDimensionIndex(metadata, pokedex.DefaultSizeCategories), test)
+}
+
+// Checks the widths of the strings in the golden file, which has a line per case like
+// <width>\t<Go quoted string>\t# <note>
+func TestUnicodeStringLengthGolden(test *testing.T) {
+	data, err := os.ReadFile("data/golden/string_widths.txt")
+	pokedex.Check(err)
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		expected, err := strconv.Atoi(fields[0])
+		pokedex.Check(err)
+		s, err := strconv.Unquote(fields[1])
+		pokedex.Check(err)
+
+		Assert(fmt.Sprintf("%s = %d", fields[1], expected), fmt.Sprintf("%s = %d", fields[1], pokedex.UnicodeStringLength(s)), test)
+	}
+}
+
+func TestEscapeLength(test *testing.T) {
+	Assert(0, pokedex.EscapeLength("abc"), test)
+	Assert(7, pokedex.EscapeLength("\033[1;31mabc"), test)
+	Assert(6, pokedex.EscapeLength("\033]0;x\aabc"), test)
+	Assert(7, pokedex.EscapeLength("\033]0;x\033\\abc"), test)
+	Assert(3, pokedex.EscapeLength("\033(Babc"), test)
+	Assert(1, pokedex.EscapeLength("\033\x80"), test)
+}
+
+func FuzzUnicodeStringLength(f *testing.F) {
+	for _, s := range []string{"", "hello", "ピカチュウ", "\033[38;5;196m▄▀\033[0m", "\033]8;;x\033\\a", "é", "👨‍👩"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(test *testing.T, s string) {
+		width := pokedex.UnicodeStringLength(s)
+		// each visible rune (or invalid byte) is at most 2 columns wide
+		if width < 0 || width > 2*utf8.RuneCountInString(s) {
+			test.Fatalf("invalid width %d for %q", width, s)
+		}
+		// complete escape sequences before the string don't change its width
+		if prefixed := pokedex.UnicodeStringLength("\033[1;31m\033]8;;x\a" + s); prefixed != width {
+			test.Fatalf("width %d with escape sequences, != %d for %q", prefixed, width, s)
+		}
+		if n := pokedex.EscapeLength(s); n < 0 || n > len(s) {
+			test.Fatalf("invalid escape length %d for %q", n, s)
+		}
+	})
+}
+
+func TestParseFortunes(test *testing.T) {
+	Assert(
+		[]string{"one", "two\n  -- lines", "three"},
+		pokedex.ParseFortunes("one\n%\ntwo\r\n  -- lines\r\n%\n%\n\nthree\n"),
+		test,
+	)
+	Assert([]string{}, pokedex.ParseFortunes("%\n"), test)
+}
+
+func TestReadFortuneFiles(test *testing.T) {
+	// the .dat index files are skipped
+	fortunes, err := pokedex.ReadFortuneFiles("./data/fortunes")
+	Assert(nil, err, test)
+	Assert(
+		[]string{"The first fortune.", "A fortune\nover two lines.\n\t-- Someone", "The last fortune."},
+		fortunes,
+		test,
+	)
+
+	fortunes, err = pokedex.ReadFortuneFiles("./data/fortunes/second")
+	Assert(nil, err, test)
+	Assert([]string{"The last fortune."}, fortunes, test)
+
+	_, err = pokedex.ReadFortuneFiles("./data/pokemon.json.dat")
+	Assert(true, err != nil, test)
+}
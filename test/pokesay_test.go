@@ -1,9 +1,22 @@
 package test
 
 import (
+	"bytes"
 	"embed"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/fatih/color"
+	"github.com/pborman/getopt/v2"
 	"github.com/tmck-code/pokesay/src/pokedex"
 	"github.com/tmck-code/pokesay/src/pokesay"
 )
@@ -20,11 +33,12 @@ var (
 func TestChooseByName(test *testing.T) {
 	names := make(map[string][]int)
 	names["hoothoot"] = []int{4}
-	result, _ := pokesay.ChooseByName(
+	result, _, err := pokesay.ChooseByName(
 		names,
 		"hoothoot",
 		GOBCowNames,
 		"data/cows",
+		pokesay.EntryFilter{},
 	)
 
 	expected := pokedex.PokemonMetadata{
@@ -39,19 +53,32 @@ func TestChooseByName(test *testing.T) {
 		},
 	}
 
+	Assert(nil, err, test)
 	Assert(expected, result, test)
 }
 
+func TestChooseNotFound(test *testing.T) {
+	names := map[string][]int{"hoothoot": {4}}
+	_, _, err := pokesay.ChooseByName(names, "pikachu", GOBCowNames, "data/cows", pokesay.EntryFilter{})
+	Assert("cannot find pokemon by name 'pikachu'", err.Error(), test)
+	Assert(true, errors.Is(err, pokesay.ErrNotFound), test)
+
+	_, _, err = pokesay.ChooseByCategory("big", nil, GOBCategories, "data/categories", GOBCowNames, "data/cows", pokesay.EntryFilter{})
+	Assert("cannot find pokemon by category 'big'", err.Error(), test)
+	Assert(true, errors.Is(err, pokesay.ErrNotFound), test)
+}
+
 func TestChooseByCategory(test *testing.T) {
 	dir, _ := GOBCategories.ReadDir("data/categories/small")
 
-	metadata, entry := pokesay.ChooseByCategory(
+	metadata, entry, err := pokesay.ChooseByCategory(
 		"small",
 		dir,
 		GOBCategories,
 		"data/categories",
 		GOBCowNames,
 		"data/cows",
+		pokesay.EntryFilter{},
 	)
 
 	expectedMetadata := pokedex.PokemonMetadata{
@@ -71,6 +98,7 @@ func TestChooseByCategory(test *testing.T) {
 		Categories: []string{"small", "gen8", "regular"},
 	}
 
+	Assert(nil, err, test)
 	Assert(expectedMetadata, metadata, test)
 	Assert(expectedEntry, entry, test)
 }
@@ -78,14 +106,16 @@ func TestChooseByCategory(test *testing.T) {
 func TestChooseByNameAndCategory(test *testing.T) {
 	names := make(map[string][]int)
 	names["hoothoot"] = []int{4}
-	metadata, entry := pokesay.ChooseByNameAndCategory(
+	metadata, entry, err := pokesay.ChooseByNameAndCategory(
 		names,
 		"hoothoot",
 		GOBCowNames,
 		"data/cows",
 		"small",
+		pokesay.EntryFilter{},
 	)
 
+	Assert(nil, err, test)
 	Assert("small", entry.Categories[0], test)
 	Assert("Hoothoot", metadata.Name, test)
 }
@@ -113,3 +143,1147 @@ func TestUnicodeStringLength(test *testing.T) {
 	}
 	Assert(expected, results, test)
 }
+
+func TestEntryFilter(test *testing.T) {
+	entries := []pokedex.PokemonEntryMapping{
+		{EntryIndex: 1, Categories: []string{"small"}, Width: 20, Height: 10},
+		{EntryIndex: 2, Categories: []string{"big"}, Width: 40, Height: 25},
+	}
+
+	Assert(entries, pokesay.EntryFilter{}.Filter(entries), test)
+	Assert(entries[:1], pokesay.EntryFilter{MaxWidth: 30}.Filter(entries), test)
+	Assert([]pokedex.PokemonEntryMapping{}, pokesay.EntryFilter{MaxWidth: 10}.Filter(entries), test)
+	Assert(entries[:1], pokesay.EntryFilter{MaxHeight: 15}.Filter(entries), test)
+	Assert(entries[:1], pokesay.EntryFilter{MaxWidth: 50, MaxHeight: 15}.Filter(entries), test)
+}
+
+func TestEntryFilterPrefer(test *testing.T) {
+	entries := []pokedex.PokemonEntryMapping{
+		{EntryIndex: 1, Dimensions: map[string]string{"gen": "gen7x", "variant": "regular"}},
+		{EntryIndex: 2, Dimensions: map[string]string{"gen": "gen7x", "variant": "shiny"}},
+		{EntryIndex: 3, Dimensions: map[string]string{"gen": "gen8", "variant": "regular"}},
+	}
+	preferences, err := pokesay.ParsePreferences([]string{"gen=gen9", "gen8", "variant=shiny"})
+
+	Assert(nil, err, test)
+	Assert(
+		[]pokesay.Preference{{Dimension: "gen", Values: []string{"gen9", "gen8"}}, {Dimension: "variant", Values: []string{"shiny"}}},
+		preferences,
+		test,
+	)
+	// gen9 doesn't exist, so gen8 is chosen, and then there's no gen8 shiny, so the preference is ignored
+	Assert(entries[2:], pokesay.EntryFilter{Prefer: preferences}.Filter(entries), test)
+	Assert(entries[1:2], pokesay.EntryFilter{Prefer: preferences[1:]}.Filter(entries), test)
+
+	_, err = pokesay.ParsePreferences([]string{"gen8"})
+	Assert("invalid preference 'gen8', must be like <dimension>=<category>[,<category>...]", err.Error(), test)
+}
+
+func TestParseCategory(test *testing.T) {
+	index := []pokedex.CategoryDimension{
+		{Name: "gen", Values: []string{"gen7x", "gen8"}},
+		{Name: "variant", Values: []string{"regular", "shiny"}},
+	}
+
+	category, err := pokesay.ParseCategory("variant=shiny", index)
+	Assert("shiny", category, test)
+	Assert(nil, err, test)
+
+	category, _ = pokesay.ParseCategory("shiny", index)
+	Assert("shiny", category, test)
+
+	_, err = pokesay.ParseCategory("gen=shiny", index)
+	Assert("cannot find category 'shiny' in dimension 'gen'", err.Error(), test)
+}
+
+func TestNamesInCategory(test *testing.T) {
+	names := map[string][]int{"hoothoot": {0}, "pikachu": {1}, "eevee": {2}}
+	metadata := []pokedex.PokemonMetadata{
+		{Entries: []pokedex.PokemonEntryMapping{{Categories: []string{"small", "regular"}}, {Categories: []string{"small", "shiny"}}}},
+		{Entries: []pokedex.PokemonEntryMapping{{Categories: []string{"small", "regular"}}}},
+		{Entries: []pokedex.PokemonEntryMapping{{Categories: []string{"big", "shiny"}}}},
+	}
+	metadataFor := func(idx int) pokedex.PokemonMetadata { return metadata[idx] }
+	keys := []string{"small", "big", "regular", "shiny"}
+	index := []pokedex.CategoryDimension{
+		{Name: "size", Values: []string{"small", "big"}},
+		{Name: "variant", Values: []string{"regular", "shiny"}},
+	}
+
+	// a category can be given with or without its dimension
+	for _, category := range []string{"shiny", "variant=shiny"} {
+		result, err := pokesay.NamesInCategory(names, category, keys, index, metadataFor)
+		Assert(nil, err, test)
+		Assert([]string{"eevee", "hoothoot"}, result, test)
+	}
+
+	_, err := pokesay.NamesInCategory(names, "size=shiny", keys, index, metadataFor)
+	Assert("cannot find category 'shiny' in dimension 'size'", err.Error(), test)
+	_, err = pokesay.NamesInCategory(names, "huge", keys, index, metadataFor)
+	Assert(true, errors.Is(err, pokesay.ErrNotFound), test)
+	Assert("cannot find pokemon by category 'huge'", err.Error(), test)
+}
+
+func TestEntryCategories(test *testing.T) {
+	entry := pokedex.PokemonEntryMapping{
+		Categories: []string{"small", "gen8", "shiny"},
+		Dimensions: map[string]string{"size": "small", "gen": "gen8", "variant": "shiny"},
+	}
+
+	Assert([]string{"small", "gen8", "shiny"}, pokesay.EntryCategories(entry, nil), test)
+	Assert([]string{"shiny", "gen8"}, pokesay.EntryCategories(entry, []string{"variant", "gen", "form"}), test)
+}
+
+func TestSplitMessages(test *testing.T) {
+	text := "hello there\n---\ngeneral kenobi\nyou are a bold one\n---\n"
+
+	Assert(
+		[]string{"hello there", "general kenobi\nyou are a bold one"},
+		pokesay.SplitMessages(text, "---"),
+		test,
+	)
+	Assert(
+		[]string{"hello there", "---", "general kenobi", "you are a bold one", "---"},
+		pokesay.SplitMessages(text, "lines"),
+		test,
+	)
+}
+
+func TestJoinBlocks(test *testing.T) {
+	blocks := [][]string{
+		{"\033[38;5;196mab\033[0m", "abc"},
+		{"x", "y", "z"},
+	}
+	expected := []string{
+		"\033[0m     x\033[0m",
+		"\033[38;5;196mab\033[0m\033[0m   y\033[0m",
+		"abc\033[0m  z\033[0m",
+	}
+	Assert(expected, pokesay.JoinBlocks(blocks, 2), test)
+}
+
+func TestClipLine(test *testing.T) {
+	// escape sequences aren't counted, and wide characters aren't split
+	Assert("\033[31mab", pokesay.ClipLine("\033[31mabcd\033[0m", 2), test)
+	Assert("ピカ", pokesay.ClipLine("ピカチュウ", 5), test)
+	Assert("abcd\033[0m", pokesay.ClipLine("abcd\033[0m", 4), test)
+	Assert("", pokesay.ClipLine("abcd", 0), test)
+	Assert("", pokesay.ClipLine("abcd", -1), test)
+}
+
+func TestReproduceCommand(test *testing.T) {
+	entries := []pokedex.PokemonEntryMapping{
+		{EntryIndex: 1586, Categories: []string{"small", "gen7x", "shiny"}},
+		{EntryIndex: 2960, Categories: []string{"small", "gen8", "regular"}},
+		{EntryIndex: 4285, Categories: []string{"small", "gen8", "shiny"}},
+		{EntryIndex: 428, Categories: []string{"small", "gen7x", "regular"}},
+	}
+
+	Assert("pokesay -n hoothoot -c shiny", pokesay.ReproduceCommand("hoothoot", entries[0], entries), test)
+	Assert("pokesay -n hoothoot -c regular", pokesay.ReproduceCommand("hoothoot", entries[1], entries), test)
+	Assert("pokesay -n hoothoot", pokesay.ReproduceCommand("hoothoot", pokedex.PokemonEntryMapping{}, entries), test)
+}
+
+func TestGenerateCompletion(test *testing.T) {
+	options := []pokesay.CompletionOption{
+		{Short: "n", Long: "name", Values: []string{"pikachu", "eevee"}},
+		{Short: "b", Long: "info-border", IsFlag: true},
+		{Long: "count"},
+	}
+	expected := `# fish completion for pokesay
+complete -c pokesay -f
+complete -c pokesay -n __fish_use_subcommand -a 'browse completion'
+complete -c pokesay -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c pokesay -s n -l name -x -a 'pikachu eevee'
+complete -c pokesay -s b -l info-border
+complete -c pokesay -l count -x
+`
+	result, err := pokesay.GenerateCompletion("fish", options, []string{"browse", "completion"})
+	Assert(nil, err, test)
+	Assert(expected, result, test)
+
+	_, err = pokesay.GenerateCompletion("powershell", options, []string{})
+	Assert("unsupported shell 'powershell', must be one of: bash, zsh, fish", err.Error(), test)
+}
+
+func TestQueryArgs(test *testing.T) {
+	query, _ := url.ParseQuery("name=pikachu&category=shiny&width=40&japanese-name&unicode-borders=true&title=Deploy+bot")
+	args, err := pokesay.QueryArgs(query)
+
+	Assert(nil, err, test)
+	Assert([]string{"pikachu"}, args.NameTokens, test)
+	Assert("shiny", args.Category, test)
+	Assert(40, args.Width, test)
+	Assert(true, args.JapaneseName, test)
+	Assert(false, args.DrawInfoBorder, test)
+	Assert(pokesay.UnicodeBoxChars, args.BoxChars, test)
+	Assert("Deploy bot", args.Title, test)
+	Assert("", args.Footer, test)
+	Assert(pokesay.DefaultIndent, args.Indent, test)
+
+	query, _ = url.ParseQuery("width=wide")
+	_, err = pokesay.QueryArgs(query)
+	Assert("invalid width 'wide', must be a number from 1 to 500", err.Error(), test)
+}
+
+func TestServeHandler(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	names := map[string][]int{"hoothoot": {4}}
+	choose := func(args pokesay.Args, name string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+		if name == "" {
+			name = "hoothoot"
+		}
+		metadata, _, err := pokesay.ChooseByName(names, name, GOBCowNames, "data/cows", pokesay.EntryFilter{})
+		if err != nil {
+			return metadata, pokedex.PokemonEntryMapping{}, err
+		}
+		// 2960 is the only entry that has a sprite in the test data
+		return metadata, metadata.Entries[1], nil
+	}
+	handler := pokesay.ServeHandler(nil, choose, GOBCowData)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(httptest.NewRequest(http.MethodGet, "/?name=hoothoot&text=hello&width=10", nil))
+	Assert(http.StatusOK, w.Code, test)
+	Assert("text/plain; charset=utf-8", w.Header().Get("Content-Type"), test)
+	Assert("/------------\\", strings.Split(w.Body.String(), "\n")[0], test)
+	Assert("| hello\033[0m      |", strings.Split(w.Body.String(), "\n")[1], test)
+	Assert(true, strings.Contains(w.Body.String(), "Hoothoot"), test)
+
+	w = serve(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("posted")))
+	Assert(http.StatusOK, w.Code, test)
+	Assert(true, strings.Contains(w.Body.String(), "| posted"), test)
+
+	for _, tc := range []struct {
+		request  *http.Request
+		status   int
+		expected string
+	}{
+		{httptest.NewRequest(http.MethodGet, "/?width=wide", nil), http.StatusBadRequest, "invalid width 'wide', must be a number from 1 to 500\n"},
+		// widths that are too large to print (or would overflow) are rejected
+		{httptest.NewRequest(http.MethodGet, "/?width=501", nil), http.StatusBadRequest, "invalid width '501', must be a number from 1 to 500\n"},
+		{httptest.NewRequest(http.MethodGet, "/?width=9223372036854775807", nil), http.StatusBadRequest, "invalid width '9223372036854775807', must be a number from 1 to 500\n"},
+		{httptest.NewRequest(http.MethodGet, "/?name=pikachu", nil), http.StatusNotFound, "cannot find pokemon by name 'pikachu'\n"},
+		// a body that is shorter than its Content-Length
+		{httptest.NewRequest(http.MethodPost, "/", iotest.ErrReader(io.ErrUnexpectedEOF)), http.StatusBadRequest, "unexpected EOF\n"},
+	} {
+		w := serve(tc.request)
+		Assert(tc.status, w.Code, test)
+		Assert(tc.expected, w.Body.String(), test)
+	}
+
+	pokesay.MaxRequestBytes = 4
+	defer func() { pokesay.MaxRequestBytes = 1 << 20 }()
+	w = serve(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too long")))
+	Assert(http.StatusRequestEntityTooLarge, w.Code, test)
+}
+
+func TestServeHandlerConcurrent(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	names := map[string][]int{"hoothoot": {4}}
+	choose := func(args pokesay.Args, name string) (pokedex.PokemonMetadata, pokedex.PokemonEntryMapping, error) {
+		metadata, _, err := pokesay.ChooseByName(names, "hoothoot", GOBCowNames, "data/cows", pokesay.EntryFilter{})
+		return metadata, metadata.Entries[1], err
+	}
+	server := httptest.NewServer(pokesay.ServeHandler(nil, choose, GOBCowData))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	codes := make([]int, 8)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := http.Get(server.URL + "/?text=hello")
+			if err == nil {
+				codes[i] = resp.StatusCode
+				resp.Body.Close()
+			}
+		}(i)
+	}
+	wg.Wait()
+	Assert([]int{200, 200, 200, 200, 200, 200, 200, 200}, codes, test)
+}
+
+func TestRandomIntConcurrent(test *testing.T) {
+	// the random source is shared by concurrent requests, which is checked by `go test -race`
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if n := pokesay.RandomInt(10); n < 0 || n >= 10 {
+					test.Errorf("random int %d is out of range", n)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLayoutGrid(test *testing.T) {
+	blocks := [][]string{{"aaa", "a"}, {"bb"}, {"cccc"}}
+
+	Assert(
+		[][]string{
+			{"aaa\033[0m  \033[0m", "a\033[0m    bb\033[0m"},
+			{"cccc\033[0m"},
+		},
+		pokesay.LayoutGrid(blocks, 8, 2),
+		test,
+	)
+	// a block that is wider than the width is put in a row on its own
+	Assert(
+		[][]string{{"aaa\033[0m", "a\033[0m"}, {"bb\033[0m"}, {"cccc\033[0m"}},
+		pokesay.LayoutGrid(blocks, 1, 2),
+		test,
+	)
+}
+
+func TestCountCategories(test *testing.T) {
+	metadata := []pokedex.PokemonMetadata{
+		{Name: "Hoothoot", Entries: []pokedex.PokemonEntryMapping{
+			{Categories: []string{"small", "gen7x", "shiny"}},
+			{Categories: []string{"small", "gen8", "shiny"}},
+		}},
+		{Name: "Noctowl", Entries: []pokedex.PokemonEntryMapping{
+			{Categories: []string{"medium", "gen8", "regular", "female"}},
+		}},
+	}
+	categories := []string{"female", "gen7x", "gen8", "medium", "regular", "shiny", "small"}
+	index := []pokedex.CategoryDimension{
+		{Name: "size", Values: []string{"small", "medium"}},
+		{Name: "gen", Values: []string{"gen7x", "gen8"}},
+		{Name: "variant", Values: []string{"regular", "shiny"}},
+	}
+
+	expected := []pokesay.CategoryInfo{
+		{Name: "small", Dimension: "size", Sprites: 2, Species: 1},
+		{Name: "medium", Dimension: "size", Sprites: 1, Species: 1},
+		{Name: "gen7x", Dimension: "gen", Sprites: 1, Species: 1},
+		{Name: "gen8", Dimension: "gen", Sprites: 2, Species: 2},
+		{Name: "regular", Dimension: "variant", Sprites: 1, Species: 1},
+		{Name: "shiny", Dimension: "variant", Sprites: 2, Species: 1},
+		{Name: "female", Dimension: "other", Sprites: 1, Species: 1},
+	}
+	Assert(expected, pokesay.CountCategories(categories, index, metadata), test)
+}
+
+func TestWriteNames(test *testing.T) {
+	names := []string{"abra", "bulbasaur", "charmander", "ditto", "eevee"}
+
+	var columns strings.Builder
+	pokesay.WriteNames(&columns, names, "columns", 24)
+	Assert("abra        ditto\nbulbasaur   eevee\ncharmander\n5 total names\n", columns.String(), test)
+
+	var csv strings.Builder
+	pokesay.WriteNames(&csv, names[:2], "csv", 24)
+	Assert("name\nabra\nbulbasaur\n", csv.String(), test)
+
+	err := pokesay.WriteNames(&csv, names, "yaml", 24)
+	Assert("invalid format 'yaml', must be one of: columns, lines, json, csv", err.Error(), test)
+}
+
+func TestWrapText(test *testing.T) {
+	Assert([]string{"the quick brown", "fox jumps over", "the lazy dog"}, pokesay.WrapText("the quick brown fox jumps over the lazy dog", 15, "word"), test)
+	// CJK text can be broken between characters, and each character is 2 columns wide
+	Assert([]string{"これは日本", "語です。"}, pokesay.WrapText("これは日本語です。", 10, "word"), test)
+	// a word that is wider than the width is hyphenated
+	Assert([]string{"a", "supercali-", "fragilist-", "ic b"}, pokesay.WrapText("a supercalifragilistic b", 10, "word"), test)
+	Assert([]string{"a", "\"superca-", "lifragil-", "istic\","}, pokesay.WrapText("a \"supercalifragilistic\",", 9, "word"), test)
+	// other long tokens (e.g. URLs & hashes) are broken without adding a hyphen
+	Assert(
+		[]string{"see https://", "example.com/", "aaaaaaaaaaaaaaaaaaaa", "aaaaa/bbbbbb done"},
+		pokesay.WrapText("see https://example.com/aaaaaaaaaaaaaaaaaaaaaaaaa/bbbbbb done", 20, "word"),
+		test,
+	)
+	// the letters at the end of a URL aren't hyphenated either, as the whole URL isn't a word
+	Assert(
+		[]string{"https://", "example.", "com/", "abcdefgh", "ij"},
+		pokesay.WrapText("https://example.com/abcdefghij", 8, "word"),
+		test,
+	)
+	Assert(
+		[]string{"commit", "3f9a1c2e7b4d8f6a", "0e5c9b2d1a7f3e8c", "4b6d0a9f"},
+		pokesay.WrapText("commit 3f9a1c2e7b4d8f6a0e5c9b2d1a7f3e8c4b6d0a9f", 16, "word"),
+		test,
+	)
+	// the active colours are re-applied on each wrapped line, and escape codes aren't counted or split
+	Assert(
+		[]string{"\033[31mred \033[1mbold", "\033[31m\033[1mtext\033[0m plain"},
+		pokesay.WrapText("\033[31mred \033[1mbold text\033[0m plain", 10, "word"),
+		test,
+	)
+	// attributes that are turned off or replaced aren't re-applied
+	Assert(
+		[]string{"\033[1mbold\033[22m \033[31mred", "\033[32mgreen", "\033[32mtext"},
+		pokesay.WrapText("\033[1mbold\033[22m \033[31mred \033[32mgreen text", 9, "word"),
+		test,
+	)
+	Assert(
+		[]string{"\033[1;38;5;196mbold red", "\033[1m\033[38;5;196mtext"},
+		pokesay.WrapText("\033[1;38;5;196mbold red text", 8, "word"),
+		test,
+	)
+	Assert([]string{""}, pokesay.WrapText("", 10, ""), test)
+}
+
+func TestWrapTextChar(test *testing.T) {
+	Assert(
+		[]string{"see https:", "//example.", "com/a1b2c3"},
+		pokesay.WrapText("see https://example.com/a1b2c3", 10, "char"),
+		test,
+	)
+	// spaces at the start of a wrapped line are skipped
+	Assert([]string{"abcd", "efgh"}, pokesay.WrapText("abcd efgh", 4, "char"), test)
+	Assert([]string{"これは日", "本語"}, pokesay.WrapText("これは日本語", 9, "char"), test)
+}
+
+func TestWrapTextJustify(test *testing.T) {
+	Assert(
+		[]string{"the   quick", "brown   fox", "jumps"},
+		pokesay.WrapText("the quick brown fox jumps", 11, "justify"),
+		test,
+	)
+	// the spaces are spread as evenly as possible, and the last line isn't justified
+	Assert([]string{"a  b c", "d e"}, pokesay.WrapText("a b c d e", 6, "justify"), test)
+}
+
+func TestWrapTextHangingIndent(test *testing.T) {
+	Assert(
+		[]string{"    -- Oscar", "    Wilde,", "    The", "    Picture"},
+		pokesay.WrapText("    -- Oscar Wilde, The Picture", 12, "word"),
+		test,
+	)
+	// the indent is put before the active colours
+	Assert([]string{"  \033[32mab", "  \033[32mcd"}, pokesay.WrapText("  \033[32mab cd", 5, "word"), test)
+	// a long word after the indent is broken on the first line, rather than leaving it empty
+	Assert([]string{"  abc-", "  def-", "  ghi-", "  jkl"}, pokesay.WrapText("  abcdefghijkl", 6, "word"), test)
+	Assert([]string{"  1234", "  5678"}, pokesay.WrapText("  12345678", 6, "word"), test)
+	// if there's no room after an indent that is too wide to keep, then it's dropped
+	Assert([]string{"abcde-", "fgh"}, pokesay.WrapText("      abcdefgh", 6, "word"), test)
+}
+
+func TestMessageInput(test *testing.T) {
+	fortunes := func() []string { return []string{"a fortune"} }
+
+	stdin, err := os.CreateTemp(test.TempDir(), "stdin")
+	Assert(nil, err, test)
+	defer stdin.Close()
+
+	// an empty STDIN is replaced by a fortune
+	input, _ := io.ReadAll(pokesay.MessageInput(stdin, false, fortunes))
+	Assert("a fortune", string(input), test)
+
+	stdin.WriteString("hello\n")
+	stdin.Seek(0, io.SeekStart)
+	input, _ = io.ReadAll(pokesay.MessageInput(stdin, false, fortunes))
+	Assert("hello\n", string(input), test)
+
+	stdin.Seek(0, io.SeekStart)
+	input, _ = io.ReadAll(pokesay.MessageInput(stdin, true, fortunes))
+	Assert("a fortune", string(input), test)
+}
+
+// readLines reads all of the lines from a LineReader
+func readLines(r *pokesay.LineReader) []string {
+	lines := make([]string, 0)
+	for r.Scan() {
+		lines = append(lines, r.Text())
+	}
+	return lines
+}
+
+func TestLineReaderLongLines(test *testing.T) {
+	// lines longer than the 64KB limit of a bufio.Scanner are read in full
+	long := strings.Repeat("a", 200000)
+	r := pokesay.NewLineReader(strings.NewReader(long+"\nb\n"), false)
+	Assert([]string{long, "b"}, readLines(r), test)
+	Assert(nil, r.Err(), test)
+}
+
+func TestLineReaderLineEndings(test *testing.T) {
+	Assert(
+		[]string{"windows", "", "old", "mac", "unix", "no newline"},
+		readLines(pokesay.NewLineReader(strings.NewReader("windows\r\n\r\nold\rmac\nunix\nno newline"), false)),
+		test,
+	)
+	Assert([]string{}, readLines(pokesay.NewLineReader(strings.NewReader(""), false)), test)
+}
+
+func TestLineReaderError(test *testing.T) {
+	r := pokesay.NewLineReader(io.MultiReader(strings.NewReader("a\n"), iotest.ErrReader(io.ErrUnexpectedEOF)), false)
+	Assert([]string{"a"}, readLines(r), test)
+	Assert(io.ErrUnexpectedEOF, r.Err(), test)
+}
+
+func TestSanitiseLine(test *testing.T) {
+	// invalid UTF-8 is replaced
+	Assert("bad�byte", pokesay.SanitiseLine("bad\xff\xfebyte", false), test)
+	// control characters are escaped, tabs & printable characters are kept
+	Assert("nul^@ bell^G del^? \ttab é", pokesay.SanitiseLine("nul\x00 bell\a del\x7f \ttab é", false), test)
+	Assert("c1�", pokesay.SanitiseLine("c1\u0085", false), test)
+	// colours & hyperlinks are kept, other escape sequences are removed
+	Assert(
+		"\033[38;5;196mred\033[0m \033]8;;https://example.com\033\\link\033]8;;\033\\ clear",
+		pokesay.SanitiseLine("\033[38;5;196mred\033[0m \033]8;;https://example.com\033\\link\033]8;;\033\\ \033[2J\033[Hclear", false),
+		test,
+	)
+	// a bare or unterminated escape sequence is escaped, rather than swallowing the text after it
+	Assert("esc ^[", pokesay.SanitiseLine("esc \033", false), test)
+	Assert("esc ^[[31", pokesay.SanitiseLine("esc \033[31", false), test)
+	// all escape sequences are removed when stripping ANSI
+	Assert("red plain", pokesay.SanitiseLine("\033[31mred\033[0m plain", true), test)
+}
+
+func TestRenderMarkup(test *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	bold, italic, code := "\033[1m", "\033[3m", "\033[7m"
+	Assert("a "+bold+"bold\033[22m b", pokesay.RenderMarkup("a **bold** b"), test)
+	Assert(italic+"italic\033[23m", pokesay.RenderMarkup("*italic*"), test)
+	// styles can be nested, and no other markup is rendered inside code
+	Assert(
+		bold+"x \033[22m\033[1;3my\033[22;23m "+code+"*z*\033[27m",
+		pokesay.RenderMarkup("**x *y*** `*z*`"),
+		test,
+	)
+	Assert(
+		"\033[31mred \033[0m\033[34mblue\033[0m\033[31m red\033[0m",
+		pokesay.RenderMarkup("[red]red [blue]blue[/] red[/]"),
+		test,
+	)
+	// markup that isn't closed, unknown tags, lone asterisks and escaped characters are printed as-is
+	Assert("2 * 3 = *6 [1] [pink]x[/] *y* `z", pokesay.RenderMarkup("2 * 3 = *6 [1] [pink]x[/] \\*y\\* `z"), test)
+
+	// the width of the rendered text doesn't include the escape codes, so it's wrapped correctly
+	lines := pokesay.WrapText(pokesay.RenderMarkup("some **bold text** and [green]green text[/] here"), 10, "word")
+	for i, expected := range []string{"some bold", "text and", "green text", "here"} {
+		Assert(expected, pokesay.SanitiseLine(lines[i], true), test)
+	}
+}
+
+func TestFindAttribution(test *testing.T) {
+	text, author := pokesay.FindAttribution([]string{"Well done is better than well said.", "", "\t-- Benjamin Franklin", ""})
+	Assert([]string{"Well done is better than well said."}, text, test)
+	Assert("Benjamin Franklin", author, test)
+
+	text, author = pokesay.FindAttribution([]string{"quote", "  — Someone"})
+	Assert([]string{"quote"}, text, test)
+	Assert("Someone", author, test)
+
+	// a line of dashes, a line that doesn't start with dashes, or a message with only an attribution
+	// aren't changed
+	for _, lines := range [][]string{{"quote", "-----"}, {"a -- b", "c -- d"}, {"-- Someone"}, {}} {
+		text, author = pokesay.FindAttribution(lines)
+		Assert(lines, text, test)
+		Assert("", author, test)
+	}
+}
+
+func TestBorderWithLabel(test *testing.T) {
+	for _, tc := range []struct {
+		boxChars   *pokesay.BoxChars
+		width      int
+		label      string
+		alignRight bool
+		expected   string
+	}{
+		{pokesay.AsciiBoxChars, 20, "hello", false, "-[ hello ]----------"},
+		{pokesay.AsciiBoxChars, 20, "hello", true, "----------[ hello ]-"},
+		{pokesay.UnicodeBoxChars, 12, "hi", false, "─┤ hi ├─────"},
+		// a label that doesn't fit is cut off, and ends with an ellipsis
+		{pokesay.AsciiBoxChars, 20, "a very long title indeed", false, "-[ a very long... ]-"},
+		{pokesay.UnicodeBoxChars, 12, "a long title", true, "─┤ a lon… ├─"},
+		// wide characters are measured in columns, and aren't cut in half
+		{pokesay.AsciiBoxChars, 16, "ピカチュウ", false, "-[ ピカチュウ ]-"},
+		{pokesay.AsciiBoxChars, 15, "ピカチュウ", false, "-[ ピカチ... ]-"},
+		{pokesay.AsciiBoxChars, 14, "ピカチュウ", false, "-[ ピカ... ]--"},
+		// if there isn't room for any of the label (or there's no label), then the edge is plain
+		{pokesay.AsciiBoxChars, 9, "hello", false, "---------"},
+		{pokesay.AsciiBoxChars, 6, "hello", false, "------"},
+		{pokesay.AsciiBoxChars, 10, "", false, "----------"},
+	} {
+		result := pokesay.BorderWithLabel(tc.boxChars, tc.width, tc.label, tc.alignRight)
+		Assert(tc.expected, result, test)
+		Assert(tc.width, pokesay.UnicodeStringLength(result), test)
+	}
+}
+
+func TestMoreLinesFooter(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	info := pokesay.PokemonInfo{Name: "Hoothoot", Categories: []string{"small"}}
+
+	for _, tc := range []struct {
+		width    int
+		text     string
+		footer   string
+		expected []string // the ellipsis line & the bottom border
+	}{
+		{40, "1\n2\n3\n4\n", "Someone", []string{"| ...\033[0m                                      |", "\\------¡-------[ (+3 more lines) Someone ]-/"}},
+		{40, "1\n2\n", "Someone", []string{"| ...\033[0m                                      |", "\\------¡--------[ (+1 more line) Someone ]-/"}},
+		// the count is kept whole, and the footer after it is cut off
+		{30, "1\n2\n3\n4\n", "a very long footer", []string{"| ...\033[0m                            |", "\\------¡-----[ (+3 more lines) ]-/"}},
+		// if the count doesn't fit in the footer, then it's put on the ellipsis line, shortened if needed
+		{20, "1\n2\n3\n4\n", "Someone", []string{"| ... (+3 more lines)\033[0m  |", "\\------¡---[ Someone ]-/"}},
+		{12, strings.Repeat("x\n", 100), "", []string{"| ... +99\033[0m      |", "\\------¡-------/"}},
+	} {
+		args := pokesay.Args{Width: tc.width, DrawBubble: true, TabSpaces: "    ", BoxChars: pokesay.AsciiBoxChars, MaxLines: 1, Footer: tc.footer}
+		var output bytes.Buffer
+		Assert(nil, pokesay.Fprint(&output, strings.NewReader(tc.text), args, 2960, info, GOBCowData), test)
+		Assert(tc.expected, strings.Split(output.String(), "\n")[2:4], test)
+	}
+}
+
+func TestPrintSceneSplitSinglePokemon(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	pokemon := []pokesay.ScenePokemon{{EntryIndex: 2960, Info: pokesay.PokemonInfo{Name: "Hoothoot"}}}
+
+	// a single pokemon speaks each message in its own speech bubble, one after another
+	var output bytes.Buffer
+	args := pokesay.Args{
+		Width: 20, DrawBubble: true, TabSpaces: "    ", BoxChars: pokesay.AsciiBoxChars, SplitDelimiter: "lines",
+		Input: strings.NewReader("hello\nworld\n"), Output: &output,
+	}
+	Assert(nil, pokesay.PrintScene(args, pokemon, GOBCowData), test)
+	lines := strings.Split(output.String(), "\n")
+	Assert("| hello\033[0m                |\033[0m", lines[1], test)
+	Assert(2, strings.Count(output.String(), "/----------------------\\"), test)
+	Assert(2, strings.Count(output.String(), "> Hoothoot"), test)
+	Assert(true, strings.Index(output.String(), "| world") > strings.Index(output.String(), "> Hoothoot"), test)
+}
+
+func TestFooterAuto(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	info := pokesay.PokemonInfo{Name: "Hoothoot", Categories: []string{"small"}}
+
+	// the output is the same as finding the attribution in all of the text, then printing it as the footer
+	for _, text := range []string{
+		"Well done is better than well said.\n\n\t-- Benjamin Franklin\n\n",
+		"\n\none\n\ntwo\n\n  -- Someone\n",
+		"one\ntwo\nthree\nfour\n-- Someone",
+		"one\n-- not the end\ntwo\n",
+		"-- Someone\n",
+		"\n\n-- Someone\n",
+		"",
+	} {
+		for _, maxLines := range []int{0, 2} {
+			args := pokesay.Args{Width: 20, DrawBubble: true, TabSpaces: "    ", BoxChars: pokesay.AsciiBoxChars, MaxLines: maxLines}
+			lines := strings.Split(text, "\n")
+			if lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			lines, author := pokesay.FindAttribution(lines)
+			var expected bytes.Buffer
+			args.Footer = author
+			input := ""
+			for _, line := range lines {
+				input += line + "\n"
+			}
+			Assert(nil, pokesay.Fprint(&expected, strings.NewReader(input), args, 2960, info, GOBCowData), test)
+
+			var result bytes.Buffer
+			args.Footer = "auto"
+			Assert(nil, pokesay.Fprint(&result, strings.NewReader(text), args, 2960, info, GOBCowData), test)
+			Assert(expected.String(), result.String(), test)
+		}
+	}
+}
+
+func TestLineLimit(test *testing.T) {
+	lines := []string{"1", "2", "3", "4", "5", "6", "7"}
+	for _, tc := range []struct {
+		head, tail bool
+		expected   []string
+	}{
+		{false, false, []string{"1", "2", "3", "..."}},
+		{true, false, []string{"1", "2", "3", "..."}},
+		{false, true, []string{"...", "5", "6", "7"}},
+		{true, true, []string{"1", "2", "...", "7"}},
+	} {
+		limit := pokesay.NewLineLimit(3, tc.head, tc.tail)
+		for _, line := range lines {
+			limit.Add(line)
+		}
+		Assert(tc.expected, limit.Lines("..."), test)
+		Assert(4, limit.Dropped(), test)
+	}
+
+	// no ellipsis is added if all of the lines fit
+	limit := pokesay.NewLineLimit(3, false, true)
+	limit.Add("1")
+	limit.Add("2")
+	Assert([]string{"1", "2"}, limit.Lines("..."), test)
+	Assert(0, limit.Dropped(), test)
+}
+
+func TestTetherRoute(test *testing.T) {
+	for _, tc := range []struct {
+		head, width     int
+		tether          int
+		expectedColumns []int
+	}{
+		// the default head column
+		{0, 84, 7, []int{8, 9, 10, 11}},
+		{12, 84, 7, []int{8, 9, 10, 11}},
+		// a head near the left edge is reached straight down, with the minimum length
+		{2, 84, 1, []int{2, 2}},
+		{40, 84, 35, []int{36, 37, 38, 39}},
+		// the tether stays within the bubble, and the string is no longer than the maximum length,
+		// even if it doesn't reach the head
+		{50, 20, 18, []int{19, 20, 21, 22, 23, 24, 25, 26}},
+		{3, 84, 1, []int{2, 3}},
+	} {
+		tether, columns := pokesay.TetherRoute(tc.head, tc.width)
+		Assert(tc.tether, tether, test)
+		Assert(tc.expectedColumns, columns, test)
+	}
+}
+
+func TestSpriteOffset(test *testing.T) {
+	for _, tc := range []struct {
+		align    string
+		indent   int
+		width    int
+		expected int
+	}{
+		{"left", 4, 20, 4},
+		{"", 0, 20, 0},
+		// the 36 column bubble has 12 spare columns after the indent & sprite
+		{"center", 4, 20, 10},
+		{"right", 4, 20, 16},
+		// sprites that are wider than the bubble are printed after the indent
+		{"right", 4, 40, 4},
+	} {
+		args := pokesay.Args{Width: 32, SpriteAlign: tc.align, Indent: tc.indent}
+		Assert(tc.expected, pokesay.SpriteOffset(args, tc.width), test)
+	}
+}
+
+func TestPrintSpriteWidth(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	info := pokesay.PokemonInfo{Name: "Hoothoot", Categories: []string{"small"}}
+
+	// the sprite is aligned with the width from its metadata, the same width that the tether uses
+	args := pokesay.Args{Width: 20, BoxChars: pokesay.AsciiBoxChars, HeadColumn: 20, SpriteAlign: "right", SpriteWidth: 10}
+	var result bytes.Buffer
+	Assert(nil, pokesay.Fprint(&result, strings.NewReader("hello"), args, 2960, info, GOBCowData), test)
+
+	args.SpriteAlign, args.Indent = "left", pokesay.SpriteOffset(args, 10)
+	var expected bytes.Buffer
+	Assert(nil, pokesay.Fprint(&expected, strings.NewReader("hello"), args, 2960, info, GOBCowData), test)
+	Assert(14, args.Indent, test)
+	Assert(expected.String(), result.String(), test)
+}
+
+func TestIndentSprite(test *testing.T) {
+	sprite := []byte("\033[38;5;16m▄▄\n▀\033[39m\n")
+
+	Assert("  \033[38;5;16m▄▄\n  ▀\033[39m\n", string(pokesay.IndentSprite(sprite, 2)), test)
+	Assert(string(sprite), string(pokesay.IndentSprite(sprite, 0)), test)
+}
+
+func TestNewPokemonInfo(test *testing.T) {
+	metadata := pokedex.PokemonMetadata{Name: "Hoothoot", JapaneseName: "ホーホー", JapanesePhonetic: "ho-ho-", Dex: "163"}
+	entry := pokedex.PokemonEntryMapping{
+		Categories: []string{"small", "gen8", "shiny"},
+		Dimensions: map[string]string{"size": "small", "gen": "gen8", "variant": "shiny"},
+	}
+
+	Assert(
+		pokesay.PokemonInfo{
+			Name: "Hoothoot", Dex: "163", Japanese: "ホーホー", JapanesePhonetic: "ho-ho-",
+			Categories: []string{"shiny"},
+			Dimensions: map[string]string{"size": "small", "gen": "gen8", "variant": "shiny"},
+		},
+		pokesay.NewPokemonInfo(metadata, entry, []string{"variant"}),
+		test,
+	)
+}
+
+func TestExpandInfoFormat(test *testing.T) {
+	values := map[string]string{"name": "Mr. Mime", "dex": "122", "categories": "small/gen8"}
+
+	result, err := pokesay.ExpandInfoFormat("**{name}** #{dex}\n{categories} {", values)
+	Assert(nil, err, test)
+	Assert("**Mr. Mime** #122\nsmall/gen8 {", result, test)
+
+	// values are never rendered as markup
+	result, _ = pokesay.ExpandInfoFormat("{name}", map[string]string{"name": "*[red]x[/]*"})
+	Assert("*[red]x[/]*", pokesay.RenderMarkup(result), test)
+
+	_, err = pokesay.ExpandInfoFormat("{nope}", values)
+	Assert(
+		"unknown placeholder '{nope}' in info format, must be one of: name, dex, jp, romaji, categories, arrow, sep, en, ja, ja-ro, zh, size, gen, variant, form",
+		err.Error(),
+		test,
+	)
+}
+
+func TestValidateInfoFormat(test *testing.T) {
+	Assert(nil, pokesay.ValidateInfoFormat(pokesay.DefaultInfoFormat([]string{"zh", "ja-ro"}, true, true)), test)
+	Assert(nil, pokesay.ValidateInfoFormat("{name} | {gen} {variant}"), test)
+	Assert(true, pokesay.ValidateInfoFormat("{japanese}") != nil, test)
+}
+
+func TestDefaultInfoFormat(test *testing.T) {
+	Assert("{arrow} **{name}** {sep} *{categories}*", pokesay.DefaultInfoFormat(nil, false, true), test)
+	Assert(
+		"{arrow} **{zh}** {sep} **{en}** {sep} **{jp} ({romaji})**",
+		pokesay.DefaultInfoFormat([]string{"zh", "en"}, true, false),
+		test,
+	)
+}
+
+func TestFilterSprite(test *testing.T) {
+	sprite := []byte("  \033[38;5;196m▄\033[48;5;16m \033[49m\033[38;2;255;0;0m▀\033[39m\n")
+	for _, tc := range []struct {
+		filter   string
+		expected string
+	}{
+		{"invert", "  \033[38;5;51m▄\033[48;5;231m \033[49m\033[38;2;0;255;255m▀\033[39m\n"},
+		{"grayscale", "  \033[38;5;239m▄\033[48;5;16m \033[49m\033[38;2;76;76;76m▀\033[39m\n"},
+		{"hue:+120", "  \033[38;5;46m▄\033[48;5;16m \033[49m\033[38;2;0;255;0m▀\033[39m\n"},
+		{"silhouette", "  \033[38;5;238m▄\033[48;5;238m \033[49m\033[38;2;68;68;68m▀\033[39m\n"},
+		{"map:16=238", "  \033[38;5;196m▄\033[48;5;238m \033[49m\033[38;2;255;0;0m▀\033[39m\n"},
+		{"map:196=#0000ff", "  \033[38;5;21m▄\033[48;5;16m \033[49m\033[38;2;0;0;255m▀\033[39m\n"},
+	} {
+		filter, err := pokesay.ParseColourFilter(tc.filter)
+		Assert(nil, err, test)
+		Assert(tc.expected, string(pokesay.FilterSprite(sprite, []pokesay.ColourFilter{filter})), test)
+	}
+	// map matches the closest 256 colour, e.g. after another filter, or for a #rrggbb colour that
+	// isn't one of the 256 colours
+	for _, tc := range []struct {
+		filters  []string
+		expected string
+	}{
+		{[]string{"grayscale", "map:239=21"}, "  \033[38;5;21m▄\033[48;5;16m \033[49m\033[38;2;0;0;255m▀\033[39m\n"},
+		{[]string{"map:#f00a0a=#0000ff"}, "  \033[38;5;21m▄\033[48;5;16m \033[49m\033[38;2;0;0;255m▀\033[39m\n"},
+	} {
+		filters := make([]pokesay.ColourFilter, 0)
+		for _, name := range tc.filters {
+			filter, err := pokesay.ParseColourFilter(name)
+			Assert(nil, err, test)
+			filters = append(filters, filter)
+		}
+		Assert(tc.expected, string(pokesay.FilterSprite(sprite, filters)), test)
+	}
+	// without any filters, the sprite isn't changed
+	Assert(string(sprite), string(pokesay.FilterSprite(sprite, nil)), test)
+}
+
+func TestParseColourFilter(test *testing.T) {
+	for _, name := range append([]string{"hue:-45", "hue:90.5", "map:16=238", "map:#000000=#444444"}, pokesay.NamedColourFilters...) {
+		_, err := pokesay.ParseColourFilter(name)
+		Assert(nil, err, test)
+	}
+	for _, name := range []string{"hue:red", "hue:NaN", "hue:Inf", "hue:-Inf", "map:16", "map:16=256", "map:-1=0", "map:#00000=0", "map:#gggggg=0"} {
+		_, err := pokesay.ParseColourFilter(name)
+		Assert(
+			"invalid filter '"+name+"', must be one of: grayscale, sepia, invert, silhouette, hue:<degrees>, map:<from>=<to>",
+			err.Error(),
+			test,
+		)
+	}
+}
+
+func TestLightBackground(test *testing.T) {
+	// the black outline is softened, white is darkened, and other colours are kept
+	sprite := []byte("\033[48;5;16m \033[38;5;196m▄\033[38;5;231m▀\033[38;2;255;255;255m▀\033[39m\n")
+	Assert(
+		"\033[48;5;239m \033[38;5;196m▄\033[38;5;253m▀\033[38;2;217;217;217m▀\033[39m\n",
+		string(pokesay.FilterSprite(sprite, []pokesay.ColourFilter{pokesay.LightBackground})),
+		test,
+	)
+}
+
+func TestReadableOnLight(test *testing.T) {
+	Assert(
+		"\033[1;30mwhite\033[0m \033[33myellow\033[0m \033[31mred\033[0m \033[38;5;97m256\033[0m",
+		pokesay.ReadableOnLight("\033[1;97mwhite\033[0m \033[93myellow\033[0m \033[31mred\033[0m \033[38;5;97m256\033[0m"),
+		test,
+	)
+}
+
+func TestDarkenDefaultText(test *testing.T) {
+	// the text in the default colour is dark, and the dark colour is re-applied after other colours
+	Assert(
+		"\033[38;5;235m\033[1mbold\033[22m \033[31mred\033[0;38;5;235m \033[38;5;0mblack\033[39;38;5;235m plain\033[39m",
+		pokesay.DarkenDefaultText("\033[1mbold\033[22m \033[31mred\033[0m \033[38;5;0mblack\033[39m plain"),
+		test,
+	)
+	Assert("", pokesay.DarkenDefaultText(""), test)
+}
+
+func TestParseBackgroundColour(test *testing.T) {
+	for _, tc := range []struct {
+		reply      string
+		expected   pokesay.RGB
+		background string
+	}{
+		{"\033]11;rgb:ffff/ffff/ffff\033\\", pokesay.RGB{255, 255, 255}, "light"},
+		{"\033]11;rgb:0000/0000/0000\a", pokesay.RGB{0, 0, 0}, "dark"},
+		{"\033]11;rgb:ff/80/00\a", pokesay.RGB{255, 128, 0}, "light"},
+		{"\033]11;rgb:2/2/2\033\\", pokesay.RGB{34, 34, 34}, "dark"},
+		// the reply to the device attributes query follows the background reply
+		{"\033]11;rgb:ffff/ffff/ffff\a\033[?62;22c", pokesay.RGB{255, 255, 255}, "light"},
+	} {
+		colour, ok := pokesay.ParseBackgroundColour(tc.reply)
+		Assert(true, ok, test)
+		Assert(tc.expected, colour, test)
+		Assert(tc.background, pokesay.BackgroundOf(colour), test)
+	}
+	for _, reply := range []string{"", "\033[?62;22c", "\033]11;rgb:ffff/ffff\a", "\033]11;rgb:fffff/0/0\a", "\033]11;rgb:zz/00/00\a"} {
+		_, ok := pokesay.ParseBackgroundColour(reply)
+		Assert(false, ok, test)
+	}
+}
+
+// writeRecorder records each write, so that the chunks of typed text can be checked
+type writeRecorder struct {
+	writes []string
+}
+
+func (r *writeRecorder) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, string(p))
+	return len(p), nil
+}
+
+func TestTypeText(test *testing.T) {
+	// each character is written separately, and escape sequences are written all at once
+	var w writeRecorder
+	pokesay.TypeText(&w, "\033[31mhi\033[0m é\n", 0, make(chan os.Signal))
+	Assert([]string{"\033[31m", "h", "i", "\033[0m", " ", "é", "\n"}, w.writes, test)
+
+	// once stopped, the rest of the text is written immediately
+	w = writeRecorder{}
+	stop := make(chan os.Signal, 1)
+	stop <- os.Interrupt
+	pokesay.TypeText(&w, "hello", time.Hour, stop)
+	Assert([]string{"h", "ello"}, w.writes, test)
+}
+
+func TestSplitTypedText(test *testing.T) {
+	top, text, bottom := "/------------\\\n", "| a          |\n| b          |\n", "\\---¡--------/\n"
+	tether := func(n int) string { return strings.Repeat("    \\\n", n) }
+
+	for _, tc := range []struct {
+		args   pokesay.Args
+		bubble string
+		before string
+		text   string
+		after  string
+	}{
+		// the tether is 4 lines long for a head at column 8, and 8 lines long for a head at column 30
+		{pokesay.Args{Width: 10, DrawBubble: true, HeadColumn: 8}, top + text + bottom + tether(4), top, text, bottom + tether(4)},
+		{pokesay.Args{Width: 10, DrawBubble: true, HeadColumn: 30}, top + text + bottom + tether(8), top, text, bottom + tether(8)},
+		// without the bubble, there's no top border
+		{pokesay.Args{Width: 10, HeadColumn: 8}, text + bottom + tether(4), "", text, bottom + tether(4)},
+		// without any text
+		{pokesay.Args{Width: 10, DrawBubble: true, HeadColumn: 8}, top + bottom + tether(4), top, "", bottom + tether(4)},
+	} {
+		before, text, after := pokesay.SplitTypedText(tc.args, tc.bubble)
+		Assert(tc.before, before, test)
+		Assert(tc.text, text, test)
+		Assert(tc.after, after, test)
+	}
+}
+
+func TestBobFrame(test *testing.T) {
+	sprite := []string{"ab", "cd"}
+	for _, tc := range []struct {
+		i        int
+		expected []string
+	}{
+		{0, []string{"ab", "cd", ""}},
+		{1, []string{"", "ab", "cd"}},
+		{2, []string{"ab", "cd", ""}},
+	} {
+		Assert(tc.expected, pokesay.BobFrame(sprite, tc.i), test)
+	}
+}
+
+func TestSparkleFrame(test *testing.T) {
+	chars, colours := pokesay.SparkleChars, pokesay.SparkleColours
+	defer func() { pokesay.SparkleChars, pokesay.SparkleColours = chars, colours }()
+	pokesay.SparkleChars, pokesay.SparkleColours = []string{"*"}, []string{"\033[33m"}
+	sparkle := "\033[33m*\033[0m"
+
+	frame := pokesay.SparkleFrame([]string{"  ab", "cdef"})
+	sparkles := 0
+	for _, line := range frame {
+		sparkles += strings.Count(line, sparkle)
+	}
+	if sparkles < 1 || sparkles > pokesay.SparkleCount {
+		test.Fatalf("expected 1 to %d sparkles, got %d", pokesay.SparkleCount, sparkles)
+	}
+	// the sparkles are only drawn in the empty cells around the sprite, which is 2 columns wider
+	// than the widest line
+	for i, line := range frame {
+		frame[i] = strings.ReplaceAll(line, sparkle, " ")
+	}
+	Assert([]string{"  ab\033[0m  ", "cdef\033[0m  "}, frame, test)
+}
+
+func TestAnimateNotTerminal(test *testing.T) {
+	pokesay.SpriteRoot = "data/cows"
+	defer func() { pokesay.SpriteRoot = "build/assets/cows" }()
+	info := pokesay.PokemonInfo{Name: "Hoothoot", Categories: []string{"small"}}
+	args := pokesay.Args{Width: 20, DrawBubble: true, TabSpaces: "    ", Indent: 4, BoxChars: pokesay.AsciiBoxChars}
+
+	var expected bytes.Buffer
+	Assert(nil, pokesay.Fprint(&expected, strings.NewReader("hello"), args, 2960, info, GOBCowData), test)
+
+	// if the output isn't a terminal, then the output is the same as without an animation
+	for animation := range pokesay.Animations {
+		var output bytes.Buffer
+		args.Animate, args.Input, args.Output = animation, strings.NewReader("hello"), &output
+		Assert(nil, pokesay.Animate(args, 2960, info, GOBCowData), test)
+		Assert(expected.String(), output.String(), test)
+	}
+
+	args.Input = iotest.ErrReader(io.ErrUnexpectedEOF)
+	Assert(io.ErrUnexpectedEOF, pokesay.Animate(args, 2960, info, GOBCowData), test)
+}
+
+func TestParseParams(test *testing.T) {
+	set := getopt.New()
+	set.BoolLong("help", 'h', "display this help message")
+	file := set.StringLong("file", 0, "", "read the message from a file")
+
+	// flags can be given before or after the parameters, and everything after a "--" is a parameter
+	params, help := pokesay.ParseParams(set, []string{"pokesay", "hello", "--file", "notes.txt", "world", "--", "-literal", "--file"})
+	Assert([]string{"hello", "world", "-literal", "--file"}, params, test)
+	Assert(false, help, test)
+	Assert("notes.txt", *file, test)
+
+	params, help = pokesay.ParseParams(set, []string{"pokesay", "hello", "-h", "world"})
+	Assert([]string{"hello"}, params, test)
+	Assert(true, help, test)
+}
+
+func TestResolveMessage(test *testing.T) {
+	dir := test.TempDir()
+	fpath, stdinPath, emptyPath := dir+"/message.txt", dir+"/stdin.txt", dir+"/empty.txt"
+	pokedex.Check(os.WriteFile(fpath, []byte("from the file\n"), 0644))
+	pokedex.Check(os.WriteFile(stdinPath, []byte("from stdin\n"), 0644))
+	pokedex.Check(os.WriteFile(emptyPath, nil, 0644))
+	fortunes := func() []string { return []string{"a fortune"} }
+
+	read := func(message []string, file string, fortune bool, stdinPath string) string {
+		stdin, err := os.Open(stdinPath)
+		pokedex.Check(err)
+		defer stdin.Close()
+		r, err := pokesay.ResolveMessage(message, file, fortune, stdin, fortunes)
+		Assert(nil, err, test)
+		text, err := io.ReadAll(r)
+		pokedex.Check(err)
+		return string(text)
+	}
+
+	for _, tc := range []struct {
+		message  []string
+		file     string
+		fortune  bool
+		stdin    string
+		expected string
+	}{
+		// the message is first, then the file, then STDIN
+		{[]string{"hello", "world"}, fpath, false, stdinPath, "hello world\n"},
+		{[]string{"-literal"}, "", false, stdinPath, "-literal\n"},
+		{nil, fpath, false, stdinPath, "from the file\n"},
+		{nil, "-", false, stdinPath, "from stdin\n"},
+		{nil, "", false, stdinPath, "from stdin\n"},
+		// a fortune is used if it's asked for, or STDIN is empty
+		{nil, "", true, stdinPath, "a fortune"},
+		{nil, "", false, emptyPath, "a fortune"},
+	} {
+		Assert(tc.expected, read(tc.message, tc.file, tc.fortune, tc.stdin), test)
+	}
+
+	for _, tc := range []struct {
+		message  []string
+		file     string
+		expected string
+	}{
+		{[]string{"hello"}, "", "cannot use --fortune with a message or --file"},
+		{nil, fpath, "cannot use --fortune with a message or --file"},
+	} {
+		_, err := pokesay.ResolveMessage(tc.message, tc.file, true, os.Stdin, fortunes)
+		Assert(tc.expected, err.Error(), test)
+	}
+	_, err := pokesay.ResolveMessage(nil, dir+"/missing.txt", false, os.Stdin, fortunes)
+	Assert(true, errors.Is(err, os.ErrNotExist), test)
+}
+
+func TestRenderInfoBox(test *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	info := pokesay.PokemonInfo{Name: "Pikachu", Japanese: "ピカチュウ", JapanesePhonetic: "pikachu", Categories: []string{"small", "gen8"}}
+	args := pokesay.Args{InfoFormat: "**{name}** {jp}\n[red]{categories}[/]", BoxChars: pokesay.UnicodeBoxChars}
+
+	Assert("\033[1mPikachu\033[22m ピカチュウ\n\033[31msmall/gen8\033[0m\n", pokesay.RenderInfoBox(args, info), test)
+
+	// the border fits around the widest line, where the escape codes are zero-width, and each
+	// japanese character is 2 columns wide
+	args.DrawInfoBorder = true
+	Assert(
+		"╭────────────────────╮\n"+
+			"│ \033[1mPikachu\033[22m ピカチュウ │\n"+
+			"│ \033[31msmall/gen8\033[0m         │\n"+
+			"╰────────────────────╯\n",
+		pokesay.RenderInfoBox(args, info),
+		test,
+	)
+	// on a light background, the text in the terminal's own colour is dark, and white or bright
+	// colours are darkened
+	args.DrawInfoBorder, args.Background = false, "light"
+	args.InfoFormat = "**{name}** *{jp}* [white]{categories}[/]"
+	Assert(
+		"\033[38;5;235m\033[1mPikachu\033[22m \033[3mピカチュウ\033[23m \033[30msmall/gen8\033[0;38;5;235m\033[39m\n",
+		pokesay.RenderInfoBox(args, info),
+		test,
+	)
+	args.DrawInfoBorder, args.Background = true, ""
+
+	// the values are never rendered as markup
+	info.Name = "*Mr. Mime*"
+	args.InfoFormat, args.BoxChars = "{name}", pokesay.AsciiBoxChars
+	Assert("/------------\\\n| *Mr. Mime* |\n\\------------/\n", pokesay.RenderInfoBox(args, info), test)
+}
+
+func TestLayoutPokemon(test *testing.T) {
+	sprite := []string{"aaaa", "bbbb", "cccc", "dddd"}
+	for _, tc := range []struct {
+		position string
+		sprite   []string
+		expected []string
+	}{
+		{"", sprite, []string{"aaaa", "bbbb", "cccc", "dddd", "x", "yy"}},
+		{"below", sprite, []string{"aaaa", "bbbb", "cccc", "dddd", "x", "yy"}},
+		{"above", sprite, []string{"x", "yy", "aaaa", "bbbb", "cccc", "dddd"}},
+		// the info box is vertically centred beside the sprite
+		{"beside", sprite, []string{"aaaa\033[0m  \033[0m", "bbbb\033[0m  x\033[0m", "cccc\033[0m  yy\033[0m", "dddd\033[0m  \033[0m"}},
+		// a sprite that is shorter than the info box is aligned to the bottom
+		{"beside", []string{"aa"}, []string{"\033[0m    x\033[0m", "aa\033[0m  yy\033[0m"}},
+	} {
+		Assert(tc.expected, pokesay.LayoutPokemon(pokesay.Args{InfoPosition: tc.position}, tc.sprite, "x\nyy\n"), test)
+	}
+}